@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"iter"
 	"math"
-	"path/filepath"
 	"reflect"
 	"regexp"
 	"runtime"
@@ -24,10 +23,55 @@ import (
 
 const _shortLimit = 100
 
-var (
-	_fgExpected = scuf.FgRGB(0x96, 0xf7, 0x59) //nolint:mnd
-	_fgActual   = scuf.FgRGB(0xff, 0x40, 0x53) //nolint:mnd
-)
+// Theme is a set of colours used in failure reports. Colours are SGR
+// parameters, e.g. "31", "1;32" or "38;2;255;64;83" for 24-bit colour.
+// 24-bit and 256 colours are downsampled to nearest colours supported by
+// terminal, detected using COLORTERM and TERM environment variables.
+type Theme struct {
+	// Expected colours expected value names
+	Expected string
+	// Actual colours actual value names
+	Actual string
+	// Label colours failure kind, e.g. "Not equal"
+	Label string
+	// StacktraceFile colours file paths in stacktrace
+	StacktraceFile string
+	// StacktraceLine colours line numbers in stacktrace
+	StacktraceLine string
+	// StacktraceFunc colours function names in stacktrace
+	StacktraceFunc string
+}
+
+var DefaultTheme = Theme{
+	Expected:       scuf.FgRGB(0x96, 0xf7, 0x59), //nolint:mnd
+	Actual:         scuf.FgRGB(0xff, 0x40, 0x53), //nolint:mnd
+	Label:          scuf.FgHiRed,
+	StacktraceFile: scuf.FgHiWhite,
+	StacktraceLine: scuf.FgGreen,
+	StacktraceFunc: scuf.FgBlue,
+}
+
+// _pkgPath is import path of this package, used to find assertion calls in sources.
+var _pkgPath = reflect.TypeFor[Config]().PkgPath()
+
+// RegisterHelper marks fn as assertion helper, so failure reports of
+// assertions inside of it show argument names from helper call site
+// instead of helper parameter names. fn must be a plain function.
+//
+//	func equalJSON(t *testing.T, expected, actual string) {
+//		t.Helper()
+//		assert.Equal(t, normalize(expected), normalize(actual))
+//	}
+//
+//	func init() { assert.RegisterHelper(equalJSON) }
+func RegisterHelper(fn any) {
+	q.RegisterHelper(fn)
+}
+
+// RegisterFormatter makes failure reports print values of type T using format.
+func RegisterFormatter[T any](format func(T) string) {
+	pp.RegisterFormatter(format)
+}
 
 func mapJoin[T any](seq iter.Seq[T], toString func(T) string, sep string) string {
 	var sb strings.Builder
@@ -40,6 +84,12 @@ func mapJoin[T any](seq iter.Seq[T], toString func(T) string, sep string) string
 	return sb.String()
 }
 
+// shorten formats value on single line if it fits along with its name,
+// on multiple lines otherwise. Folded value keeps focus visible.
+func shorten(printer *pp.PrettyPrinter, name string, value any, focus int) string {
+	return printer.SprintAround(value, focus, _shortLimit-scuf.Width(name)-1)
+}
+
 // isTest tells whether name looks like a test or benchmark, according to prefix.
 // It is a Test (say) if there is a character after Test that is not a lower-case letter.
 // We don't want TesticularCancer.
@@ -70,6 +120,23 @@ type caller struct {
 	funcName string
 }
 
+// testPackage returns import path of package of test file calling assertion,
+// or empty string if there is no such file in call stack.
+func testPackage() string {
+	for c := range callerInfo() {
+		if !strings.HasSuffix(c.file, "_test.go") {
+			continue
+		}
+
+		// e.g. github.com/a/b_test.TestX.func1
+		i := strings.LastIndexByte(c.funcName, '/') + 1
+		if j := strings.IndexByte(c.funcName[i:], '.'); j != -1 {
+			return c.funcName[:i+j]
+		}
+	}
+	return ""
+}
+
 // callerInfo returns an array of strings containing the file and line number
 // of each stack frame leading from the current test to the assert call that
 // failed.
@@ -104,12 +171,11 @@ func callerInfo() iter.Seq[caller] {
 			}
 
 			parts := strings.Split(file, "/")
-			file = parts[len(parts)-1]
 			if len(parts) > 1 {
 				dir := parts[len(parts)-2]
-				if dir != "assert" && dir != "mock" && dir != "require" || file == "mock_test.go" {
-					path, _ := filepath.Abs(file)
-					if !yield(caller{path, line, name}) {
+				if dir != "assert" && dir != "mock" && dir != "require" || parts[len(parts)-1] == "mock_test.go" {
+					// file is original one also for rewritten test files, see powerassert.RewriteSource
+					if !yield(caller{file, line, name}) {
 						return
 					}
 				}
@@ -135,71 +201,79 @@ func Equal[E any](t T, expected, actual E) {
 		return
 	}
 
-	argNames := q.Q("assert", "Equal")
+	argNames := q.Q(_pkgPath, "Equal")
+	cfg := GetConfig(t)
 	expectedName := cmp.Or(argNames[1], "Expected")
 	actualName := cmp.Or(argNames[2], "Actual")
 
-	fail(t, []labeledContent{
+	lines := []labeledContent{
 		{
-			scuf.String("Not equal", scuf.FgHiRed),
-			mapJoin(diff(expected, actual), func(line diffLine) string {
-				if line.expected == nil { // TODO: remove
-					return line.selector
-				}
-
-				shorten := func(name, s string) string {
-					// TODO: do string width if this code is kept
-					short := strings.NewReplacer(
-						"{\n    ", "{",
-						",\n    ", ", ",
-						",\n", "",
-					).Replace(s)
-					if len(name)+len(s) < _shortLimit {
-						return short
-					}
+			scuf.String("Not equal", cfg.Theme.Label),
+			renderDiff(cfg, expectedName, actualName, diff(expected, actual)),
+		},
+	}
+	if cfg.GoSyntax {
+		lines = append(lines, labeledContent{
+			scuf.String(actualName, cfg.Theme.Actual) + " as Go code",
+			pp.SprintGoIn(testPackage(), actual),
+		})
+	}
+	fail(t, lines)
+}
 
-					return s
-				}
+// renderDiff renders diff of values named expectedName and actualName.
+func renderDiff(cfg Config, expectedName, actualName string, lines iter.Seq[diffLine]) string {
+	printer := cfg.printer()
+	return mapJoin(lines, func(line diffLine) string {
+		if line.expected == nil { // TODO: remove
+			return line.selector
+		}
 
-				expectedStr := shorten(expectedName, pp.Sprint(line.expected))
-				actualStr := shorten(actualName, pp.Sprint(line.actual))
+		expectedStr := shorten(printer, expectedName, line.expected, line.focus)
+		actualStr := shorten(printer, actualName, line.actual, line.focus)
 
-				if strings.ContainsRune(expectedStr, '\n') || strings.ContainsRune(actualStr, '\n') {
-					return fun.Ternary(line.comment != "", line.comment+":\n", "") +
-						scuf.String(expectedName+line.selector, _fgExpected) + " = " + expectedStr + "\n" +
-						scuf.String(actualName+line.selector, _fgActual) + " = " + actualStr
-				}
+		if strings.ContainsRune(expectedStr, '\n') || strings.ContainsRune(actualStr, '\n') {
+			return fun.Ternary(line.comment != "", line.comment+":\n", "") +
+				scuf.String(expectedName+line.selector, cfg.Theme.Expected) + " = " + expectedStr + "\n" +
+				scuf.String(actualName+line.selector, cfg.Theme.Actual) + " = " + actualStr
+		}
 
-				comment := fun.Ternary(line.comment != "", ", "+line.comment, "")
-				return scuf.String(expectedName+line.selector, _fgExpected) + " != " + scuf.String(actualName+line.selector, _fgActual) + comment + ":\n" +
-					"\t" + expectedStr + " !=\n" +
-					"\t" + actualStr
-			}, "\n\n"),
-		},
-	})
+		comment := fun.Ternary(line.comment != "", ", "+line.comment, "")
+		return scuf.String(expectedName+line.selector, cfg.Theme.Expected) + " != " + scuf.String(actualName+line.selector, cfg.Theme.Actual) + comment + ":\n" +
+			"\t" + expectedStr + " !=\n" +
+			"\t" + actualStr
+	}, "\n\n")
 }
 
-func fail(t T, lines []labeledContent) {
-	t.Helper()
-
-	stacktraceLabeledContent := labeledContent{
+func stacktrace(theme Theme) labeledContent {
+	return labeledContent{
 		scuf.String("Stacktrace", scuf.ModFaint),
 		mapJoin(callerInfo(), func(v caller) string {
 			j := strings.LastIndexByte(v.funcName, '/')
 			shortFuncName := v.funcName[j+1:]
-			return scuf.String(v.file, scuf.FgHiWhite) +
+			return scuf.String(v.file, theme.StacktraceFile) +
 				":" +
-				scuf.String(strconv.Itoa(v.line), scuf.FgGreen) +
+				scuf.String(strconv.Itoa(v.line), theme.StacktraceLine) +
 				"\t" +
-				scuf.String(shortFuncName, scuf.FgBlue)
+				scuf.String(shortFuncName, theme.StacktraceFunc)
 		}, "\n"),
 	}
+}
+
+func fail(t T, lines []labeledContent) {
+	t.Helper()
 
-	lines = append([]labeledContent{stacktraceLabeledContent}, lines...)
-	t.Error("\n" + mapJoin(slices.Values(lines), func(v labeledContent) string {
+	cfg := GetConfig(t)
+	lines = append([]labeledContent{stacktrace(cfg.Theme)}, lines...)
+	writeHTMLReport(t, lines)
+	msg := "\n" + mapJoin(slices.Values(lines), func(v labeledContent) string {
 		return v.label + ":\n    " +
 			strings.ReplaceAll(v.content, "\n", "\n    ")
-	}, "\n"))
+	}, "\n")
+	if !cfg.Colors {
+		msg = scuf.Strip(msg)
+	}
+	t.Error(msg)
 }
 
 func NotEqual[E any](t T, expected, actual E) {
@@ -208,23 +282,29 @@ func NotEqual[E any](t T, expected, actual E) {
 		return
 	}
 
-	argNames := q.Q("assert", "NotEqual")
+	argNames := q.Q(_pkgPath, "NotEqual")
+	cfg := GetConfig(t)
 	expectedName := cmp.Or(argNames[1], "Expected")
 	actualName := cmp.Or(argNames[2], "Actual")
 
 	fail(t, []labeledContent{
 		{
-			scuf.String("Equal", scuf.FgHiRed),
-			fmt.Sprintf(
-				"%s and %s are equal, asserted not to, value is:\n\t%s",
-				scuf.String(expectedName, _fgExpected),
-				scuf.String(actualName, _fgActual),
-				strings.ReplaceAll(pp.Sprint(expected), "\n", "\n\t"),
-			),
+			scuf.String("Equal", cfg.Theme.Label),
+			renderEqual(cfg, expectedName, actualName, expected),
 		},
 	})
 }
 
+// renderEqual renders value of equal values named expectedName and actualName.
+func renderEqual(cfg Config, expectedName, actualName string, value any) string {
+	return fmt.Sprintf(
+		"%s and %s are equal, asserted not to, value is:\n\t%s",
+		scuf.String(expectedName, cfg.Theme.Expected),
+		scuf.String(actualName, cfg.Theme.Actual),
+		strings.ReplaceAll(cfg.printer().Sprint(value), "\n", "\n\t"),
+	)
+}
+
 func Zero[E any](t T, actual E) {
 	t.Helper()
 	var zero E
@@ -232,43 +312,31 @@ func Zero[E any](t T, actual E) {
 		return
 	}
 
-	argNames := q.Q("assert", "Zero")
+	argNames := q.Q(_pkgPath, "Zero")
+	cfg := GetConfig(t)
+	printer := cfg.printer()
 	expectedName := "Zero"
 	actualName := cmp.Or(argNames[1], "Actual")
 
 	fail(t, []labeledContent{
 		{
-			scuf.String("Not equal", scuf.FgHiRed),
+			scuf.String("Not equal", cfg.Theme.Label),
 			mapJoin(diff(zero, actual), func(line diffLine) string {
 				if line.expected == nil { // TODO: remove
 					return line.selector
 				}
 
-				shorten := func(name, s string) string {
-					// TODO: do string width if this code is kept
-					short := strings.NewReplacer(
-						"{\n    ", "{",
-						",\n    ", ", ",
-						",\n", "",
-					).Replace(s)
-					if len(name)+len(s) < _shortLimit {
-						return short
-					}
-
-					return s
-				}
-
-				expectedStr := shorten(expectedName, pp.Sprint(line.expected))
-				actualStr := shorten(actualName, pp.Sprint(line.actual))
+				expectedStr := shorten(printer, expectedName, line.expected, line.focus)
+				actualStr := shorten(printer, actualName, line.actual, line.focus)
 
 				if strings.ContainsRune(expectedStr, '\n') || strings.ContainsRune(actualStr, '\n') {
 					return fun.Ternary(line.comment == "", "", line.comment+":") + "\n" +
-						scuf.String(expectedName+line.selector, _fgExpected) + " = " + expectedStr + "\n" +
-						scuf.String(actualName+line.selector, _fgActual) + " = " + actualStr
+						scuf.String(expectedName+line.selector, cfg.Theme.Expected) + " = " + expectedStr + "\n" +
+						scuf.String(actualName+line.selector, cfg.Theme.Actual) + " = " + actualStr
 				}
 
 				comment := fun.Ternary(line.comment == "", "", ", "+line.comment)
-				return scuf.String(expectedName+line.selector, _fgExpected) + " != " + scuf.String(actualName+line.selector, _fgActual) + comment + ":\n" +
+				return scuf.String(expectedName+line.selector, cfg.Theme.Expected) + " != " + scuf.String(actualName+line.selector, cfg.Theme.Actual) + comment + ":\n" +
 					"\t" + expectedStr + " != " + actualStr
 			}, "\n\n"),
 		},
@@ -282,13 +350,14 @@ func NotZero[E any](t T, actual E) {
 		return
 	}
 
-	argNames := q.Q("assert", "NotZero")
+	argNames := q.Q(_pkgPath, "NotZero")
+	cfg := GetConfig(t)
 	actualName := cmp.Or(argNames[1], "Actual")
 
 	fail(t, []labeledContent{
 		{
-			scuf.String("Value is zero", scuf.FgHiRed),
-			scuf.String(actualName, _fgActual) + " is zero, asserted not to",
+			scuf.String("Value is zero", cfg.Theme.Label),
+			scuf.String(actualName, cfg.Theme.Actual) + " is zero, asserted not to",
 		},
 	})
 }
@@ -299,13 +368,14 @@ func True(t T, condition bool) {
 		return
 	}
 
-	argNames := q.Q("assert", "True")
+	argNames := q.Q(_pkgPath, "True")
+	cfg := GetConfig(t)
 	conditionName := cmp.Or(argNames[1], "Condition")
 
 	fail(t, []labeledContent{
 		{
 			"Condition is false",
-			conditionName + scuf.String(" is false", scuf.FgHiRed),
+			conditionName + scuf.String(" is false", cfg.Theme.Label),
 		},
 	})
 }
@@ -316,13 +386,14 @@ func False(t T, condition bool) {
 		return
 	}
 
-	argNames := q.Q("assert", "False")
+	argNames := q.Q(_pkgPath, "False")
+	cfg := GetConfig(t)
 	conditionName := cmp.Or(argNames[1], "Condition")
 
 	fail(t, []labeledContent{
 		{
 			"Condition is true",
-			conditionName + scuf.String(" is true", scuf.FgHiRed),
+			conditionName + scuf.String(" is true", cfg.Theme.Label),
 		},
 	})
 }
@@ -333,13 +404,14 @@ func NoError(t T, err error) {
 		return
 	}
 
-	argNames := q.Q("assert", "NoError")
+	argNames := q.Q(_pkgPath, "NoError")
+	cfg := GetConfig(t)
 	errorName := cmp.Or(argNames[1], "Error")
 
 	fail(t, []labeledContent{
 		{
 			"Unexpected error",
-			errorName + " is " + pp.Sprint(err.Error()),
+			errorName + " is " + cfg.printer().Sprint(err.Error()),
 		},
 	})
 }
@@ -350,15 +422,16 @@ func SliceContains[E comparable](t T, slice []E, item E) {
 		return
 	}
 
-	argNames := q.Q("assert", "SliceContains")
+	argNames := q.Q(_pkgPath, "SliceContains")
+	cfg := GetConfig(t)
 	sliceName := cmp.Or(argNames[1], "Slice")
 	itemName := cmp.Or(argNames[2], "Item")
 
 	fail(t, []labeledContent{
 		{
 			label: "Slice does not contain item",
-			content: sliceName + ": " + pp.Sprint(slice) + "\n" +
-				itemName + ": " + pp.Sprint(item),
+			content: sliceName + ": " + cfg.printer().Sprint(slice) + "\n" +
+				itemName + ": " + cfg.printer().Sprint(item),
 		},
 	})
 }
@@ -371,15 +444,16 @@ func MapContainsValue[K, V comparable](t T, m map[K]V, item V) {
 		}
 	}
 
-	argNames := q.Q("assert", "MapContainsValue")
+	argNames := q.Q(_pkgPath, "MapContainsValue")
+	cfg := GetConfig(t)
 	mapName := cmp.Or(argNames[1], "Map")
 	itemName := cmp.Or(argNames[2], "Value")
 
 	fail(t, []labeledContent{
 		{
 			label: "Map does not contain value",
-			content: mapName + ": " + pp.Sprint(m) + "\n" +
-				itemName + ": " + pp.Sprint(item),
+			content: mapName + ": " + cfg.printer().Sprint(m) + "\n" +
+				itemName + ": " + cfg.printer().Sprint(item),
 		},
 	})
 }
@@ -392,15 +466,16 @@ func MapContainsKey[K comparable, V any](t T, m map[K]V, item K) {
 		}
 	}
 
-	argNames := q.Q("assert", "MapContainsKey")
+	argNames := q.Q(_pkgPath, "MapContainsKey")
+	cfg := GetConfig(t)
 	mapName := cmp.Or(argNames[1], "Map")
 	itemName := cmp.Or(argNames[2], "Key")
 
 	fail(t, []labeledContent{
 		{
 			label: "Map does not contain key",
-			content: mapName + ": " + pp.Sprint(m) + "\n" +
-				itemName + ": " + pp.Sprint(item),
+			content: mapName + ": " + cfg.printer().Sprint(m) + "\n" +
+				itemName + ": " + cfg.printer().Sprint(item),
 		},
 	})
 }
@@ -411,15 +486,16 @@ func Substring(t T, text, substr string) {
 		return
 	}
 
-	argNames := q.Q("assert", "Substring")
+	argNames := q.Q(_pkgPath, "Substring")
+	cfg := GetConfig(t)
 	textName := cmp.Or(argNames[1], "Text")
 	needleName := cmp.Or(argNames[2], "Substring")
 
 	fail(t, []labeledContent{
 		{
 			label: "String does not contain substring",
-			content: textName + ": " + pp.Sprint(text) + "\n" +
-				needleName + ": " + pp.Sprint(substr),
+			content: textName + ": " + cfg.printer().Sprint(text) + "\n" +
+				needleName + ": " + cfg.printer().Sprint(substr),
 		},
 	})
 }
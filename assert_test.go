@@ -1,10 +1,16 @@
 package assert
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/rprtr258/assert/internal/ass"
+	"github.com/rprtr258/assert/internal/scuf"
 )
 
 type Pass struct {
@@ -29,3 +35,199 @@ func TestDiffImpl(t *testing.T) {
 	))
 	ass.Equal(t, expected, actual)
 }
+
+func TestDiffMap(t *testing.T) {
+	expected := []diffLine{{expected: 1, actual: 2, selector: "[a]"}}
+	actual := slices.Collect(diffImpl("", map[string]int{"a": 1}, map[string]int{"a": 2}))
+	ass.Equal(t, expected, actual)
+}
+
+func TestDiffFocus(t *testing.T) {
+	lines := slices.Collect(diffImpl("", "abcd", "abxd"))
+	ass.Equal(t, 2, lines[0].focus)
+
+	lines = slices.Collect(diffImpl("", []int{1, 2, 3}, []int{1, 5}))
+	ass.Equal(t, 1, lines[0].focus)
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.HTMLReportDir = dir
+	SetConfig(t, cfg)
+
+	writeHTMLReport(t, []labeledContent{
+		{"Not equal", scuf.String("a", cfg.Theme.Expected) + " != " + scuf.String("b", cfg.Theme.Actual) + "\n\t<1> != <2>"},
+	})
+
+	entries, err := os.ReadDir(dir)
+	ass.NoError(t, err)
+	ass.Equal(t, 1, len(entries))
+	ass.True(t, strings.HasPrefix(entries[0].Name(), "TestWriteHTMLReport-"))
+
+	report, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	ass.NoError(t, err)
+	ass.SContains(t, "<h3>TestWriteHTMLReport</h3>", string(report))
+	ass.SContains(t, "<dt>Not equal</dt>", string(report))
+	ass.SContains(t, "&lt;1&gt; != &lt;2&gt;", string(report))
+	ass.SContains(t, "<style>", string(report))
+}
+
+// fakeT records failure messages, it is named as subtests are.
+type fakeT struct {
+	T
+	name string
+	errs []string
+}
+
+func (t *fakeT) Name() string      { return t.name }
+func (t *fakeT) Helper()           {}
+func (t *fakeT) Cleanup(func())    {}
+func (t *fakeT) Error(args ...any) { t.errs = append(t.errs, fmt.Sprint(args...)) }
+
+func TestConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Colors = false
+	cfg.ExportedOnly = true
+	SetConfig(t, cfg)
+
+	t.Run("inherited", func(t *testing.T) {
+		ass.Equal(t, cfg, GetConfig(t))
+
+		ft := &fakeT{name: t.Name()}
+		user := User{"a", Pass{"b", "c"}}
+		NotEqual(ft, user, user)
+		ass.Equal(t, 1, len(ft.errs))
+		ass.SContainsNot(t, "\x1b[", ft.errs[0])
+		ass.SContainsNot(t, "salt", ft.errs[0])
+	})
+
+	t.Run("overridden", func(t *testing.T) {
+		sub := DefaultConfig()
+		sub.MaxDepth = 1
+		SetConfig(t, sub)
+		ass.Equal(t, 1, GetConfig(t).MaxDepth)
+	})
+
+	ass.Equal(t, DefaultConfig(), GetConfig(&fakeT{name: "TestOther/sub"}))
+}
+
+// namelessT is T without Name method.
+type namelessT struct {
+	T
+	cleanups []func()
+}
+
+func (t *namelessT) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+
+func TestConfigNameless(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxDepth = 1
+
+	nt := &namelessT{}
+	SetConfig(nt, cfg)
+	ass.Equal(t, cfg, GetConfig(nt))
+	ass.Equal(t, cfg, GetConfig(Must(nt)))
+	ass.Equal(t, DefaultConfig(), GetConfig(&namelessT{}))
+
+	for _, f := range nt.cleanups {
+		f()
+	}
+	ass.Equal(t, DefaultConfig(), GetConfig(nt))
+}
+
+type level int
+
+func (l level) String() string { return [...]string{"low", "high"}[l] }
+
+func TestConfigMethods(t *testing.T) {
+	equal := func(t *testing.T, cfg Config) string {
+		cfg.Colors = false
+		SetConfig(t, cfg)
+		ft := &fakeT{name: t.Name()}
+		Equal(ft, level(0), level(1))
+		ass.Equal(t, 1, len(ft.errs))
+		return ft.errs[0]
+	}
+
+	t.Run("off", func(t *testing.T) {
+		ass.SContainsNot(t, "high", equal(t, DefaultConfig()))
+	})
+
+	t.Run("on", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Methods = MethodsOn
+		ass.SContains(t, "high", equal(t, cfg))
+	})
+
+	t.Run("per type", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.TypeMethods = map[reflect.Type]MethodMode{reflect.TypeFor[level](): MethodsVerbose}
+		out := equal(t, cfg)
+		ass.SContains(t, "high", out)
+		ass.SContains(t, "1", out)
+	})
+}
+
+func TestGoSyntax(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Colors = false
+	cfg.GoSyntax = true
+	SetConfig(t, cfg)
+
+	ft := &fakeT{name: t.Name()}
+	Equal(ft, User{}, User{"a", Pass{"b", "c"}})
+	ass.Equal(t, 1, len(ft.errs))
+	_, code, _ := strings.Cut(ft.errs[0], "as Go code:\n")
+	ass.SContains(t, "    User{\n    \tLogin: \"a\",\n    \tpass: Pass{", code)
+	ass.SContainsNot(t, "assert.", code)
+}
+
+func TestRerunArgs(t *testing.T) {
+	args := rerunArgs("./pkg")
+	ass.Equal(t, "test", args[0])
+	ass.Equal(t, "-json", args[1]) // -test.v is set by it
+
+	i := slices.Index(args, "./pkg")
+	ass.True(t, i > 0)
+	for _, arg := range args[1:i] {
+		ass.True(t, strings.HasPrefix(arg, "-"))
+	}
+	// only flags of user invocation are forwarded
+	for _, arg := range args[i+1:] {
+		name, _, _ := strings.Cut(strings.TrimPrefix(arg, "-test."), "=")
+		ass.Contains(t, name, _rerunTestFlags...)
+	}
+	ass.ContainsNot(t, "-test.paniconexit0=true", args...)
+}
+
+func TestRerunResultOutput(t *testing.T) {
+	res := rerunResult{
+		events: []testEvent{
+			{Action: "output", Test: "TestA", Output: "=== RUN   TestA\n", OutputType: "frame"},
+			{Action: "output", Test: "TestA", Output: "    a_test.go:1: a\n", OutputType: ""},
+			{Action: "output", Test: "TestAB", Output: "    a_test.go:2: ab\n", OutputType: ""},
+			{Action: "output", Test: "TestA/sub", Output: "        a_test.go:3: sub\n", OutputType: ""},
+			{Action: "output", Test: "TestA", Output: "--- FAIL: TestA (0.00s)\n", OutputType: "frame"},
+		},
+		failed: map[string]bool{"TestA": true},
+	}
+	ass.Equal(t, "    a_test.go:1: a\n        a_test.go:3: sub", res.output("TestA"))
+	ass.Equal(t, "", res.output("TestB"))
+}
+
+func TestSetTheme(t *testing.T) {
+	defer SetTheme(DefaultTheme)
+
+	// configs are read concurrently by parallel tests
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		GetConfig(&fakeT{name: "TestOther"})
+	}()
+	SetTheme(Theme{Label: scuf.FgBlue})
+	<-done
+	theme := GetConfig(&fakeT{name: "TestOther"}).Theme
+	ass.Equal(t, scuf.FgBlue, theme.Label)
+	ass.Equal(t, DefaultTheme.Expected, theme.Expected)
+}
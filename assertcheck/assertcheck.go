@@ -0,0 +1,391 @@
+// Package assertcheck defines an Analyzer that reports misuse of assert
+// package, which is otherwise found only when tests fail or panic:
+//   - Equal and NotEqual with expected and actual values swapped;
+//   - EqualError with error which may be nil, so that test panics;
+//   - Assert and Require called in non-test files, where they are not
+//     rewritten and only skip the test;
+//   - Regexp with invalid constant pattern;
+//   - SliceLen and MapLen with length of checked collection itself passed as
+//     expected length, so that they always pass. Their arguments swapped, like
+//     SliceLen(t, xs, n), are not reported, since such calls do not type
+//     check and compiler reports them anyway.
+//
+// Run it with go vet:
+//
+//	go install github.com/rprtr258/assert/cmd/assertcheck
+//	go vet -vettool=$(which assertcheck) ./...
+package assertcheck
+
+import (
+	"bytes"
+	"go/ast"
+	"go/constant"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const _assertPkgPath = "github.com/rprtr258/assert"
+
+// Analyzer reports misuse of assert package.
+var Analyzer = &analysis.Analyzer{
+	Name:     "assertcheck",
+	Doc:      "report misuse of github.com/rprtr258/assert",
+	URL:      "https://pkg.go.dev/github.com/rprtr258/assert/assertcheck",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector) //nolint:forcetypeassert // inspect.Analyzer result
+	insp.WithStack([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+
+		call := n.(*ast.CallExpr) //nolint:forcetypeassert // only calls are inspected
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != _assertPkgPath {
+			return true
+		}
+
+		switch fn.Name() {
+		case "Equal", "NotEqual":
+			checkSwapped(pass, call, fn.Name())
+		case "EqualError":
+			checkEqualError(pass, call, stack)
+		case "Assert", "Require":
+			checkNonTestFile(pass, call, fn.Name())
+		case "Regexp":
+			checkRegexp(pass, call)
+		case "SliceLen", "MapLen":
+			checkLen(pass, call, fn.Name(), stack)
+		}
+		return true
+	})
+	return nil, nil //nolint:nilnil // analyzer has no result
+}
+
+// render returns source code of n.
+func render(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, n)
+	return buf.String()
+}
+
+// isConst returns true if e is constant or nil.
+func isConst(pass *analysis.Pass, e ast.Expr) bool {
+	tv, ok := pass.TypesInfo.Types[e]
+	return ok && (tv.Value != nil || tv.IsNil())
+}
+
+// _actualNames and _expectedNames are variable names telling which value is
+// checked and which one it is compared with.
+var (
+	_actualNames   = map[string]bool{"got": true, "actual": true, "res": true, "result": true}
+	_expectedNames = map[string]bool{"want": true, "expected": true, "exp": true}
+)
+
+// name returns name of variable e, if it is variable.
+func name(e ast.Expr) string {
+	if ident, ok := ast.Unparen(e).(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func checkSwapped(pass *analysis.Pass, call *ast.CallExpr, funcName string) {
+	if len(call.Args) != 3 {
+		return
+	}
+
+	expected, actual := call.Args[1], call.Args[2]
+	swapped := isConst(pass, actual) && !isConst(pass, expected) ||
+		_actualNames[name(expected)] || _expectedNames[name(actual)]
+	if !swapped {
+		return
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos:     expected.Pos(),
+		End:     actual.End(),
+		Message: funcName + " arguments are expected value, then actual one, they seem to be swapped",
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message: "Swap expected and actual values",
+			TextEdits: []analysis.TextEdit{
+				{Pos: expected.Pos(), End: expected.End(), NewText: []byte(render(pass.Fset, actual))},
+				{Pos: actual.Pos(), End: actual.End(), NewText: []byte(render(pass.Fset, expected))},
+			},
+		}},
+	})
+}
+
+// comparesNil returns true if x and y are err and nil in any order.
+func comparesNil(pass *analysis.Pass, x, y ast.Expr, err types.Object) bool {
+	isErr := func(e ast.Expr) bool {
+		ident, ok := ast.Unparen(e).(*ast.Ident)
+		return ok && pass.TypesInfo.Uses[ident] == err
+	}
+	isNil := func(e ast.Expr) bool {
+		tv, ok := pass.TypesInfo.Types[e]
+		return ok && tv.IsNil()
+	}
+	return isErr(x) && isNil(y) || isNil(x) && isErr(y)
+}
+
+// impliesNotNil returns true if cond being equal to truth implies err is not
+// nil, e.g. err != nil is true or err == nil || done is false.
+func impliesNotNil(pass *analysis.Pass, cond ast.Expr, err types.Object, truth bool) bool {
+	switch cond := ast.Unparen(cond).(type) {
+	case *ast.UnaryExpr:
+		return cond.Op == token.NOT && impliesNotNil(pass, cond.X, err, !truth)
+	case *ast.BinaryExpr:
+		switch {
+		case cond.Op == token.LAND && truth, cond.Op == token.LOR && !truth:
+			return impliesNotNil(pass, cond.X, err, truth) || impliesNotNil(pass, cond.Y, err, truth)
+		case cond.Op == token.NEQ && truth, cond.Op == token.EQL && !truth:
+			return comparesNil(pass, cond.X, cond.Y, err)
+		}
+	}
+	return false
+}
+
+// checksNotNil returns true if stmt fails test or returns when err is nil.
+func checksNotNil(pass *analysis.Pass, stmt ast.Stmt, err types.Object) bool {
+	switch stmt := stmt.(type) {
+	case *ast.IfStmt: // if err == nil { t.Fatal(...) }
+		if !impliesNotNil(pass, stmt.Cond, err, false) || len(stmt.Body.List) == 0 {
+			return false
+		}
+
+		switch last := stmt.Body.List[len(stmt.Body.List)-1].(type) {
+		case *ast.ReturnStmt:
+			return true
+		case *ast.ExprStmt:
+			call, ok := last.X.(*ast.CallExpr)
+			if !ok {
+				return false
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			return ok && (strings.HasPrefix(sel.Sel.Name, "Fatal") || sel.Sel.Name == "FailNow" || sel.Sel.Name == "SkipNow")
+		}
+	case *ast.ExprStmt: // assert.NotZero(assert.Must(t), err), assert.Require(t, err != nil)
+		call, ok := stmt.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+		if !ok || fn.Pkg() == nil || fn.Pkg().Path() != _assertPkgPath || len(call.Args) < 2 {
+			return false
+		}
+
+		switch fn.Name() {
+		case "NotZero":
+			ident, ok := ast.Unparen(call.Args[1]).(*ast.Ident)
+			return ok && pass.TypesInfo.Uses[ident] == err
+		case "NotEqual":
+			return len(call.Args) == 3 && comparesNil(pass, call.Args[1], call.Args[2], err)
+		case "Require", "Assert":
+			return impliesNotNil(pass, call.Args[1], err, true)
+		}
+	}
+	return false
+}
+
+// assigned returns true if err is assigned in root between from and to.
+func assigned(pass *analysis.Pass, root ast.Node, err types.Object, from, to token.Pos) bool {
+	res := false
+	ast.Inspect(root, func(n ast.Node) bool {
+		assign, ok := n.(*ast.AssignStmt)
+		if !ok || assign.Pos() < from || assign.Pos() >= to {
+			return !res
+		}
+
+		for _, lhs := range assign.Lhs {
+			if ident, ok := ast.Unparen(lhs).(*ast.Ident); ok && pass.TypesInfo.ObjectOf(ident) == err {
+				res = true
+			}
+		}
+		return !res
+	})
+	return res
+}
+
+// guarded returns true if err is checked not to be nil before call, last in
+// stack, by statements preceding enclosing ones in their blocks or by
+// conditions of enclosing if statements, and is not assigned after the check.
+func guarded(pass *analysis.Pass, stack []ast.Node, err types.Object) bool {
+	call := stack[len(stack)-1]
+	for i := len(stack) - 2; i >= 0; i-- {
+		switch n := stack[i].(type) {
+		case *ast.BlockStmt:
+			for _, prev := range n.List {
+				if prev == stack[i+1] {
+					break
+				}
+				if checksNotNil(pass, prev, err) && !assigned(pass, n, err, prev.End(), call.Pos()) {
+					return true
+				}
+			}
+		case *ast.IfStmt:
+			var branch ast.Node
+			switch {
+			case stack[i+1] == n.Body && impliesNotNil(pass, n.Cond, err, true):
+				branch = n.Body
+			case stack[i+1] == n.Else && impliesNotNil(pass, n.Cond, err, false):
+				branch = n.Else
+			default:
+				continue
+			}
+			if !assigned(pass, branch, err, branch.Pos(), call.Pos()) {
+				return true
+			}
+		case *ast.FuncDecl, *ast.FuncLit:
+			return false // checks outside may not hold when function is called
+		}
+	}
+	return false
+}
+
+func checkEqualError(pass *analysis.Pass, call *ast.CallExpr, stack []ast.Node) {
+	if len(call.Args) != 3 {
+		return
+	}
+
+	errArg := call.Args[2]
+	var err *types.Var
+	if ident, ok := ast.Unparen(errArg).(*ast.Ident); ok {
+		err, _ = pass.TypesInfo.Uses[ident].(*types.Var)
+	}
+	if err == nil && !isConst(pass, errArg) {
+		return // only variables and nil are checked
+	}
+
+	// find statement with the call
+	var stmt ast.Stmt
+	for i := len(stack) - 2; i >= 0; i-- {
+		if _, ok := stack[i].(*ast.BlockStmt); ok {
+			stmt, _ = stack[i+1].(ast.Stmt)
+			break
+		}
+	}
+	if stmt == nil {
+		return
+	}
+	if err != nil && guarded(pass, stack, err) {
+		return
+	}
+
+	diag := analysis.Diagnostic{
+		Pos:            errArg.Pos(),
+		End:            errArg.End(),
+		Message:        "EqualError panics if " + render(pass.Fset, errArg) + " is nil, check it is not nil first",
+		SuggestedFixes: nil,
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && err != nil {
+		pkg := render(pass.Fset, sel.X) + "."
+		indent := strings.Repeat("\t", pass.Fset.Position(stmt.Pos()).Column-1)
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message: "Require error to be not nil",
+			TextEdits: []analysis.TextEdit{{
+				Pos:     stmt.Pos(),
+				End:     stmt.Pos(),
+				NewText: []byte(pkg + "NotZero(" + pkg + "Must(" + render(pass.Fset, call.Args[0]) + "), " + err.Name() + ")\n" + indent),
+			}},
+		}}
+	}
+	pass.Report(diag)
+}
+
+func checkNonTestFile(pass *analysis.Pass, call *ast.CallExpr, funcName string) {
+	if strings.HasSuffix(pass.Fset.File(call.Pos()).Name(), "_test.go") {
+		return
+	}
+
+	diag := analysis.Diagnostic{
+		Pos:            call.Pos(),
+		End:            call.End(),
+		Message:        funcName + " is rewritten only in test files, here it skips the test, use True instead",
+		SuggestedFixes: nil,
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && len(call.Args) == 2 {
+		edits := []analysis.TextEdit{{Pos: sel.Sel.Pos(), End: sel.Sel.End(), NewText: []byte("True")}}
+		if funcName == "Require" {
+			tb := call.Args[0]
+			edits = append(edits, analysis.TextEdit{
+				Pos:     tb.Pos(),
+				End:     tb.End(),
+				NewText: []byte(render(pass.Fset, sel.X) + ".Must(" + render(pass.Fset, tb) + ")"),
+			})
+		}
+		diag.SuggestedFixes = []analysis.SuggestedFix{{Message: "Use True", TextEdits: edits}}
+	}
+	pass.Report(diag)
+}
+
+func checkRegexp(pass *analysis.Pass, call *ast.CallExpr) {
+	if len(call.Args) != 3 {
+		return
+	}
+
+	re := call.Args[1]
+	tv, ok := pass.TypesInfo.Types[re]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+
+	pattern := constant.StringVal(tv.Value)
+	_, err := regexp.Compile(pattern)
+	if err == nil {
+		return
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos:     re.Pos(),
+		End:     re.End(),
+		Message: "invalid regular expression: " + err.Error(),
+		SuggestedFixes: []analysis.SuggestedFix{{
+			Message:   "Match pattern literally",
+			TextEdits: []analysis.TextEdit{{Pos: re.Pos(), End: re.End(), NewText: []byte(strconv.Quote(regexp.QuoteMeta(pattern)))}},
+		}},
+	})
+}
+
+func checkLen(pass *analysis.Pass, call *ast.CallExpr, funcName string, stack []ast.Node) {
+	if len(call.Args) != 3 {
+		return
+	}
+
+	lenCall, ok := ast.Unparen(call.Args[1]).(*ast.CallExpr)
+	if !ok || len(lenCall.Args) != 1 {
+		return
+	}
+	if builtin, ok := typeutil.Callee(pass.TypesInfo, lenCall).(*types.Builtin); !ok || builtin.Name() != "len" {
+		return
+	}
+	if render(pass.Fset, lenCall.Args[0]) != render(pass.Fset, call.Args[2]) {
+		return
+	}
+
+	diag := analysis.Diagnostic{
+		Pos:            call.Args[1].Pos(),
+		End:            call.Args[2].End(),
+		Message:        funcName + " arguments are expected length, then collection, here length of the collection itself is expected, so it always passes",
+		SuggestedFixes: nil,
+	}
+	if stmt, ok := stack[len(stack)-2].(*ast.ExprStmt); ok {
+		diag.SuggestedFixes = []analysis.SuggestedFix{{
+			Message:   "Remove check which always passes",
+			TextEdits: []analysis.TextEdit{{Pos: stmt.Pos(), End: stmt.End(), NewText: nil}},
+		}}
+	}
+	pass.Report(diag)
+}
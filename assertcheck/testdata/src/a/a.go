@@ -0,0 +1,9 @@
+package a
+
+import "github.com/rprtr258/assert"
+
+func check(t assert.T, x int) {
+	assert.Assert(t, x > 0)  // want `Assert is rewritten only in test files`
+	assert.Require(t, x > 0) // want `Require is rewritten only in test files`
+	assert.True(t, x > 0)
+}
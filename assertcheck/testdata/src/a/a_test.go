@@ -0,0 +1,70 @@
+package a
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rprtr258/assert"
+)
+
+func parse() (int, error) { return 0, errors.New("bad") }
+
+func done() bool { return false }
+
+func TestEqual(t *testing.T) {
+	got, want := 1, 2
+	assert.Equal(t, want, got)
+	assert.Equal(t, got, 2)    // want `Equal arguments are expected value, then actual one`
+	assert.Equal(t, got, want) // want `Equal arguments are expected value, then actual one`
+	assert.NotEqual(t, 2, got)
+	assert.Assert(t, got == want)
+}
+
+func TestEqualError(t *testing.T) {
+	_, err := parse()
+	assert.EqualError(t, "bad", err) // want `EqualError panics if err is nil`
+	assert.EqualError(t, "bad", nil) // want `EqualError panics if nil is nil`
+
+	_, err = parse()
+	if err == nil {
+		t.Fatal("no error")
+	}
+	assert.EqualError(t, "bad", err)
+
+	_, err2 := parse()
+	assert.NotZero(assert.Must(t), err2)
+	assert.EqualError(t, "bad", err2)
+
+	if err != nil {
+		assert.EqualError(t, "bad", err)
+	}
+	if err == nil || done() {
+		t.Log("no error")
+	} else {
+		assert.EqualError(t, "bad", err)
+	}
+	if err != nil {
+		_, err = parse()
+		assert.EqualError(t, "bad", err) // want `EqualError panics if err is nil`
+	}
+
+	_, err3 := parse()
+	assert.NotZero(assert.Must(t), err3)
+	_, err3 = parse()
+	assert.EqualError(t, "bad", err3) // want `EqualError panics if err3 is nil`
+
+	assert.EqualError(t, "bad", errors.New("bad"))
+}
+
+func TestRegexp(t *testing.T) {
+	assert.Regexp(t, `a(b`, "a(b") // want "invalid regular expression: error parsing regexp: missing closing \\): `a\\(b`"
+	assert.Regexp(t, `a(b)`, "ab")
+}
+
+func TestLen(t *testing.T) {
+	xs := []int{1}
+	assert.SliceLen(t, len(xs), xs) // want `SliceLen arguments are expected length, then collection`
+	assert.SliceLen(t, 1, xs)
+	m := map[int]int{}
+	assert.MapLen(t, len(m), m) // want `MapLen arguments are expected length, then collection`
+}
@@ -0,0 +1,22 @@
+// Package assert is stub of github.com/rprtr258/assert for analyzer tests.
+package assert
+
+type T interface {
+	Helper()
+	Fatal(args ...any)
+}
+
+type tT struct{ T }
+
+func Must(t T) *tT { return &tT{t} }
+
+func Equal[E any](t T, expected, actual E)                 {}
+func NotEqual[E any](t T, expected, actual E)              {}
+func NotZero[E any](t T, actual E)                         {}
+func True(t T, condition bool)                             {}
+func Regexp(t T, re, text string)                          {}
+func EqualError(t T, expectedErrText string, err error)    {}
+func SliceLen[E any](t T, lenn int, slice []E)             {}
+func MapLen[K comparable, V any](t T, lenn int, m map[K]V) {}
+func Assert(tb T, cond bool, msgAndArgs ...any)            {}
+func Require(tb T, cond bool, msgAndArgs ...any)           {}
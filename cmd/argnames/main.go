@@ -0,0 +1,83 @@
+// Command argnames generates table of argument names of assert calls made in
+// package in current directory, so that argument names are shown in failure
+// reports even if test binary is run without sources, e.g. when built with
+// -trimpath or copied to another machine. Use it with go generate:
+//
+//	//go:generate go run github.com/rprtr258/assert/cmd/argnames
+//
+// Generated file must be regenerated when calls are moved.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rprtr258/assert/internal/q"
+)
+
+const _assertPkgPath = "github.com/rprtr258/assert"
+
+func generate(pkgName, output string) ([]byte, error) {
+	filenames, err := filepath.Glob("*.go")
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by argnames. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	prefix := "assert."
+	if pkgName == "assert" { // assert package own tests
+		prefix = ""
+	} else {
+		fmt.Fprintf(&b, "import %q\n\n", _assertPkgPath)
+	}
+	fmt.Fprintf(&b, "func init() {\n\t%sSECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZRegisterArgNames(map[string][]string{\n", prefix)
+	for _, filename := range filenames {
+		if filename == output {
+			continue
+		}
+
+		sites, err := q.CallSites(filename, _assertPkgPath)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, site := range sites {
+			names := make([]string, len(site.Names))
+			for i, name := range site.Names {
+				names[i] = strconv.Quote(name)
+			}
+			fmt.Fprintf(&b, "\t\t%q: {%s},\n", site.Key(), strings.Join(names, ", "))
+		}
+	}
+	b.WriteString("\t})\n}\n")
+
+	return format.Source(b.Bytes())
+}
+
+func main() {
+	output := flag.String("o", "zz_argnames_test.go", "output file")
+	pkgName := flag.String("pkg", os.Getenv("GOPACKAGE"), "package of output file, defaults to $GOPACKAGE")
+	flag.Parse()
+
+	if *pkgName == "" {
+		log.Fatal("package name is not set, run with go generate or set -pkg")
+	}
+
+	src, err := generate(*pkgName, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*output, src, 0o644); err != nil { //nolint:gosec // generated source is not secret
+		log.Fatal(err)
+	}
+}
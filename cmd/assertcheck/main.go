@@ -0,0 +1,16 @@
+// Command assertcheck reports misuse of assert package, see package
+// assertcheck for checks made. Run it with go vet:
+//
+//	go install github.com/rprtr258/assert/cmd/assertcheck
+//	go vet -vettool=$(which assertcheck) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/unitchecker"
+
+	"github.com/rprtr258/assert/assertcheck"
+)
+
+func main() {
+	unitchecker.Main(assertcheck.Analyzer)
+}
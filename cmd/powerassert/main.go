@@ -0,0 +1,257 @@
+// Command powerassert is go test -toolexec wrapper, which rewrites Assert and
+// Require calls in test files when they are compiled, so power assert works
+// in place with all go test flags and build cache:
+//
+//	go install github.com/rprtr258/assert/cmd/powerassert
+//	go test -toolexec=powerassert ./...
+//
+// Without it, Assert and Require rerun tests of the module from its rewritten
+// temporary copy.
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/ast"
+	goimporter "go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rprtr258/assert/internal/powerassert"
+)
+
+// selfID returns hash of powerassert executable, so that build cache is
+// invalidated when rewriter changes.
+func selfID() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// toolVersion runs tool -V=full, used by go command as tool id in build
+// cache keys, and adds powerassert id to its output.
+func toolVersion(tool string, args []string) error {
+	var stdout bytes.Buffer
+	cmd := exec.Command(tool, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	id, err := selfID()
+	if err != nil {
+		return fmt.Errorf("get powerassert id: %w", err)
+	}
+
+	fmt.Println(versionWithID(strings.TrimSpace(stdout.String()), id))
+	return nil
+}
+
+// versionWithID adds powerassert id to tool version. Devel toolchains end it
+// with buildID= field, which go command requires to stay last.
+func versionWithID(version, id string) string {
+	fields := strings.Fields(version)
+	if last := len(fields) - 1; last > 0 && strings.HasPrefix(fields[last], "buildID=") {
+		return strings.Join(slices.Insert(fields, last, "powerassert="+id), " ")
+	}
+	return version + " powerassert=" + id
+}
+
+// importer returns importer of packages compiled to files listed in
+// importcfg file given to compiler.
+func importer(fset *token.FileSet, importcfg string) (types.Importer, error) {
+	cfg, err := os.ReadFile(importcfg)
+	if err != nil {
+		return nil, fmt.Errorf("read importcfg: %w", err)
+	}
+
+	packageFiles, importMap := map[string]string{}, map[string]string{}
+	for line := range strings.Lines(string(cfg)) {
+		verb, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		from, to, _ := strings.Cut(args, "=")
+		switch verb {
+		case "packagefile":
+			packageFiles[from] = to
+		case "importmap":
+			importMap[from] = to
+		}
+	}
+
+	return goimporter.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		if mapped, ok := importMap[path]; ok {
+			path = mapped
+		}
+		file, ok := packageFiles[path]
+		if !ok {
+			return nil, fmt.Errorf("package %s is not in importcfg", path)
+		}
+		return os.Open(file)
+	}), nil
+}
+
+// check returns types of expressions of package compiled from files with
+// compiler arguments args. Type errors are ignored, since compiler reports
+// them anyway, and types are known at least partially.
+func check(fset *token.FileSet, files []*ast.File, args []string) (*types.Info, error) {
+	cfg := types.Config{Error: func(error) {}} //nolint:exhaustruct // defaults are fine
+	var pkgPath string
+	for i, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok && i+1 < len(args) {
+			value = args[i+1]
+		}
+		switch name {
+		case "-p":
+			pkgPath = value
+		case "-lang":
+			cfg.GoVersion = value
+		case "-importcfg":
+			imp, err := importer(fset, value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Importer = imp
+		}
+	}
+
+	info := &types.Info{ //nolint:exhaustruct // only these are used
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	_, _ = cfg.Check(pkgPath, fset, files, info)
+	return info, nil
+}
+
+// rewriteArgs replaces test files among compiler arguments with their
+// rewritten copies in dir.
+func rewriteArgs(dir string, args []string) ([]string, error) {
+	if !slices.ContainsFunc(args, func(arg string) bool { return strings.HasSuffix(arg, "_test.go") }) {
+		return args, nil
+	}
+
+	fset := token.NewFileSet()
+	var (
+		files []*ast.File
+		srcs  = map[*ast.File][]byte{}
+		index = map[*ast.File]int{}
+	)
+	for i, arg := range args {
+		if !strings.HasSuffix(arg, ".go") {
+			continue
+		}
+
+		src, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("read source file: %w", err)
+		}
+
+		// positions in rewritten file refer to original one
+		original, err := filepath.Abs(arg)
+		if err != nil {
+			return nil, fmt.Errorf("get source file path: %w", err)
+		}
+
+		root, err := parser.ParseFile(fset, original, src, 0)
+		if err != nil {
+			return nil, fmt.Errorf("parse source file %s: %w", arg, err)
+		}
+		files = append(files, root)
+		srcs[root], index[root] = src, i
+	}
+
+	info, err := check(fset, files, args)
+	if err != nil {
+		return nil, err
+	}
+
+	res := slices.Clone(args)
+	for _, root := range files {
+		original := fset.File(root.Pos()).Name()
+		if !strings.HasSuffix(original, "_test.go") {
+			continue
+		}
+
+		rewritten, ok, err := powerassert.RewriteFile(original, fset, root, srcs[root], info)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite test file: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		i := index[root]
+		filename := filepath.Join(dir, fmt.Sprintf("%d_%s", i, filepath.Base(args[i])))
+		if err := os.WriteFile(filename, rewritten, 0o600); err != nil {
+			return nil, fmt.Errorf("write rewritten file: %w", err)
+		}
+		res[i] = filename
+	}
+	return res, nil
+}
+
+func run(tool string, args []string) error {
+	if strings.TrimSuffix(filepath.Base(tool), ".exe") == "compile" {
+		if len(args) == 1 && args[0] == "-V=full" {
+			return toolVersion(tool, args)
+		}
+
+		dir, err := os.MkdirTemp("", "powerassert.*")
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		args, err = rewriteArgs(dir, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command(tool, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("powerassert: ")
+
+	if len(os.Args) < 2 { //nolint:mnd // program and tool
+		log.Fatal("usage: go test -toolexec=powerassert [packages]")
+	}
+
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		log.Fatal(err)
+	}
+}
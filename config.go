@@ -0,0 +1,201 @@
+package assert
+
+import (
+	"cmp"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rprtr258/assert/internal/pp"
+)
+
+// Config configures failure reports of a test. Start from DefaultConfig when
+// changing it, since zero Config disables colours and folding.
+type Config struct {
+	// Theme is colours of failure reports, empty colours are taken from DefaultTheme
+	Theme Theme
+	// Colors enables colouring of failure reports
+	Colors bool
+	// MaxDepth limits depth of printed values, -1 prints all levels
+	MaxDepth int
+	// FoldThreshold is length of strings and collections from which only
+	// their head and tail are printed, 0 disables folding
+	FoldThreshold int
+	// ExportedOnly skips unexported fields of printed structs
+	ExportedOnly bool
+	// PrintMapTypes prints map types before map literals
+	PrintMapTypes bool
+	// HTMLReportDir is a directory where each failure is written to as
+	// self-contained HTML fragment, reports are not written if empty.
+	// Defaults to ASSERT_HTML_REPORT_DIR environment variable.
+	HTMLReportDir string
+	// GoSyntax enables printing actual value of failed Equal as Go code, so
+	// expectations can be updated quickly. Code is valid in package of test
+	// calling Equal: its types are not qualified, unexported fields of other
+	// packages are omitted.
+	// Defaults to true if ASSERT_GO_SYNTAX environment variable is set to 1.
+	GoSyntax bool
+	// DiagramWidth is maximum width of power assert diagrams, values not
+	// fitting into it are moved to numbered legend below diagram, 0 disables
+	// limit
+	DiagramWidth int
+	// Methods is whether Error, String and GoString methods are used for
+	// printing values of types not in TypeMethods
+	Methods MethodMode
+	// TypeMethods is whether methods are used for printing values of given
+	// types, overriding Methods
+	TypeMethods map[reflect.Type]MethodMode
+}
+
+// MethodMode defines whether Error, String and GoString methods of values
+// are used for printing them.
+type MethodMode = pp.MethodMode
+
+const (
+	// MethodsOff prints values by their structure only.
+	MethodsOff = pp.MethodsOff
+	// MethodsOn prints result of Error, String or GoString method, in that
+	// order of preference, instead of value structure.
+	MethodsOn = pp.MethodsOn
+	// MethodsVerbose prints both result of method and value structure.
+	MethodsVerbose = pp.MethodsVerbose
+)
+
+// DefaultConfig returns config used by tests with no config set.
+func DefaultConfig() Config {
+	opts := pp.DefaultOptions()
+	return Config{
+		Theme:         DefaultTheme,
+		Colors:        true,
+		MaxDepth:      opts.MaxDepth,
+		FoldThreshold: opts.BufferFoldThreshold,
+		ExportedOnly:  opts.ExportedOnly,
+		PrintMapTypes: opts.PrintMapTypes,
+		HTMLReportDir: os.Getenv("ASSERT_HTML_REPORT_DIR"),
+		GoSyntax:      os.Getenv("ASSERT_GO_SYNTAX") == "1",
+		DiagramWidth:  _shortLimit,
+		Methods:       opts.Methods,
+		TypeMethods:   nil,
+	}
+}
+
+var (
+	_defaultConfig = DefaultConfig()
+	// _defaultTheme is theme set by SetTheme, if any
+	_defaultTheme atomic.Pointer[Theme]
+)
+
+// defaultConfig returns config of tests without config set by SetConfig.
+func defaultConfig() Config {
+	cfg := _defaultConfig
+	if theme := _defaultTheme.Load(); theme != nil {
+		cfg.Theme = *theme
+	}
+	return cfg
+}
+
+// orDefault returns theme with empty colours taken from DefaultTheme.
+func (theme Theme) orDefault() Theme {
+	return Theme{
+		Expected:       cmp.Or(theme.Expected, DefaultTheme.Expected),
+		Actual:         cmp.Or(theme.Actual, DefaultTheme.Actual),
+		Label:          cmp.Or(theme.Label, DefaultTheme.Label),
+		StacktraceFile: cmp.Or(theme.StacktraceFile, DefaultTheme.StacktraceFile),
+		StacktraceLine: cmp.Or(theme.StacktraceLine, DefaultTheme.StacktraceLine),
+		StacktraceFunc: cmp.Or(theme.StacktraceFunc, DefaultTheme.StacktraceFunc),
+	}
+}
+
+// SetTheme sets colours used in failure reports of tests without config set by
+// SetConfig, empty colours are taken from DefaultTheme. It is safe to call
+// it while tests run in parallel.
+func SetTheme(theme Theme) {
+	theme = theme.orDefault()
+	_defaultTheme.Store(&theme)
+}
+
+// _configs maps test names to their configs. Tests without names are keyed
+// by their T values.
+var _configs sync.Map
+
+// configKey returns key of config of test t in _configs, ok is false if t
+// has no name and its value cannot be used as key.
+func configKey(t T) (key any, ok bool) {
+	switch tt := t.(type) {
+	case *tT:
+		return configKey(tt.T)
+	case tT:
+		return configKey(tt.T)
+	case interface{ Name() string }:
+		return tt.Name(), true
+	default:
+		return t, reflect.TypeOf(t).Comparable()
+	}
+}
+
+// SetConfig sets config of failure reports of test t and its subtests, which
+// do not set their own. Config is removed when test finishes. Config of t
+// without Name method is used only for t itself, it is not set if t is not
+// comparable.
+func SetConfig(t T, cfg Config) {
+	cfg.Theme = cfg.Theme.orDefault()
+
+	key, ok := configKey(t)
+	if !ok {
+		return
+	}
+
+	_configs.Store(key, cfg)
+	t.Cleanup(func() {
+		_configs.Delete(key)
+	})
+}
+
+// GetConfig returns config of test t, inherited from closest parent test if
+// t has none. DefaultConfig is returned if no config is set.
+func GetConfig(t T) Config {
+	key, ok := configKey(t)
+	if !ok {
+		return defaultConfig()
+	}
+
+	name, ok := key.(string)
+	if !ok {
+		if cfg, ok := _configs.Load(key); ok {
+			return cfg.(Config) //nolint:forcetypeassert // only configs are stored
+		}
+		return defaultConfig()
+	}
+
+	for {
+		if cfg, ok := _configs.Load(name); ok {
+			return cfg.(Config) //nolint:forcetypeassert // only configs are stored
+		}
+
+		i := strings.LastIndexByte(name, '/')
+		if i == -1 {
+			return defaultConfig()
+		}
+		name = name[:i]
+	}
+}
+
+// printer returns pretty printer configured according to cfg, other options
+// are taken from pp.Default.
+func (cfg Config) printer() *pp.PrettyPrinter {
+	opts := pp.Default.Options()
+	opts.ColoringEnabled = cfg.Colors
+	opts.MaxDepth = cfg.MaxDepth
+	opts.ExportedOnly = cfg.ExportedOnly
+	opts.PrintMapTypes = cfg.PrintMapTypes
+	opts.BufferFoldThreshold = cmp.Or(cfg.FoldThreshold, math.MaxInt)
+	opts.StringFoldThreshold = cmp.Or(cfg.FoldThreshold, math.MaxInt)
+	opts.Methods = cfg.Methods
+	for typ, mode := range cfg.TypeMethods {
+		opts = opts.WithTypeMethods(typ, mode)
+	}
+	return pp.NewWithOptions(opts)
+}
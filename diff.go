@@ -13,6 +13,32 @@ type diffLine struct {
 	selector         string
 	comment          string
 	expected, actual any
+	// focus is index of first differing element or byte of expected and actual,
+	// kept visible when they are printed folded
+	focus int
+}
+
+// firstDiff returns index of first differing element of eval and aval,
+// or length of shorter one if it is prefix of other.
+func firstDiff(eval, aval reflect.Value) int {
+	n := min(eval.Len(), aval.Len())
+	for i := range n {
+		if !reflect.DeepEqual(valueToInterface(eval.Index(i)), valueToInterface(aval.Index(i))) {
+			return i
+		}
+	}
+	return n
+}
+
+// commonPrefixLen returns length of common prefix of strings in bytes.
+func commonPrefixLen(e, a string) int {
+	n := min(len(e), len(a))
+	for i := range n {
+		if e[i] != a[i] {
+			return i
+		}
+	}
+	return n
 }
 
 func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
@@ -48,12 +74,12 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 		case reflect.Invalid:
 			return func(func(diffLine) bool) {}
 		case reflect.Bool:
-			if e, a := expected.(bool), actual.(bool); e != a { //nolint:forcetypeassert
+			if e, a := eval.Bool(), aval.Bool(); e != a {
 				return fun.FromMany(diffLine{
 					selector: selectorPrefix,
 					comment:  "",
-					expected: e,
-					actual:   a,
+					expected: expected, // not e, so that methods of named types are used for printing
+					actual:   actual,
 				})
 			}
 
@@ -63,8 +89,8 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 				return fun.FromMany(diffLine{
 					selector: selectorPrefix,
 					comment:  "",
-					expected: e,
-					actual:   a,
+					expected: expected,
+					actual:   actual,
 				})
 			}
 
@@ -74,8 +100,8 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 				return fun.FromMany(diffLine{
 					selector: selectorPrefix,
 					comment:  "",
-					expected: e,
-					actual:   a,
+					expected: expected,
+					actual:   actual,
 				})
 			}
 
@@ -85,8 +111,8 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 				return fun.FromMany(diffLine{
 					selector: selectorPrefix,
 					comment:  "",
-					expected: e,
-					actual:   a,
+					expected: expected,
+					actual:   actual,
 				})
 			}
 
@@ -96,8 +122,8 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 				return fun.FromMany(diffLine{
 					selector: selectorPrefix,
 					comment:  "",
-					expected: e,
-					actual:   a,
+					expected: expected,
+					actual:   actual,
 				})
 			}
 
@@ -107,8 +133,9 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 				return fun.FromMany(diffLine{
 					selector: selectorPrefix,
 					comment:  "",
-					expected: e,
-					actual:   a,
+					expected: expected,
+					actual:   actual,
+					focus:    commonPrefixLen(e, a),
 				})
 			}
 
@@ -128,6 +155,7 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 					comment:  fmt.Sprintf("len: %d != %d", lenExpected, lenActual),
 					expected: expected,
 					actual:   actual,
+					focus:    firstDiff(eval, aval),
 				})
 			}
 
@@ -226,8 +254,8 @@ func diffImpl(selectorPrefix string, expected, actual any) iter.Seq[diffLine] {
 					func(k any) iter.Seq[diffLine] {
 						return diffImpl(
 							fmt.Sprintf("%s[%v]", selectorPrefix, k),
-							eval.MapIndex(reflect.ValueOf(k)),
-							aval.MapIndex(reflect.ValueOf(k)),
+							valueToInterface(eval.MapIndex(reflect.ValueOf(k))),
+							valueToInterface(aval.MapIndex(reflect.ValueOf(k))),
 						)
 					})(yield)
 				fun.Map(
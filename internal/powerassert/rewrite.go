@@ -0,0 +1,854 @@
+// Package powerassert rewrites Assert and Require calls of assert package, so
+// that values of predicate sub-expressions are captured and shown on failure.
+package powerassert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+const (
+	// _pkgPath is import path of assert package.
+	_pkgPath = "github.com/rprtr258/assert"
+	// _internals is name of assert package variable with functions called by
+	// rewritten code.
+	_internals = "SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__"
+)
+
+func sprintCode(n ast.Node) string {
+	buf := &bytes.Buffer{}
+	_ = printer.Fprint(buf, token.NewFileSet(), n)
+	return buf.String()
+}
+
+// rewriter rewrites predicate expression, so that values of its
+// sub-expressions are captured with their positions. Expressions are
+// rewritten keeping their semantics:
+//   - every sub-expression stays in place and is evaluated once, its value is
+//     captured by ZZZAdd wrapping it, right after evaluation;
+//   - addressable operands are not replaced by copies, only variables and
+//     their fields are read once more to be captured by ZZZAddBefore, which
+//     has no side effects;
+//   - types are not touched, untyped constants, which would get their default
+//     type instead of one given by context, are left as they are;
+//   - short-circuit evaluation is preserved, operands which may be skipped are
+//     recorded to be shown as not evaluated.
+type rewriter struct {
+	// offset is position of predicate start
+	offset token.Pos
+	// packages are import paths of packages imported in file by names they
+	// are referred by
+	packages map[string]string
+	// lazy are {position, start, end} rows of operands, which are
+	// not evaluated if && or || operators are short-circuited, with position
+	// of their value and their span
+	lazy []ast.Expr
+	// equal are {position, xPosition, yPosition, xStart, xEnd, yStart, yEnd, top}
+	// rows of == comparisons and of top comparison, with positions of their
+	// values and of values of their operands, spans of operands, and whether
+	// comparison is top one
+	equal []ast.Expr
+	// top is equality comparison deciding predicate, if any
+	top ast.Expr
+	// internals refers to SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__
+	// the same way as rewritten call refers to assert package
+	internals ast.Expr
+	// info are types of expressions, if known
+	info *types.Info
+}
+
+// _literalTypes are types of basic literals.
+var _literalTypes = map[token.Token]types.Type{
+	token.INT:    types.Typ[types.UntypedInt],
+	token.FLOAT:  types.Typ[types.UntypedFloat],
+	token.IMAG:   types.Typ[types.UntypedComplex],
+	token.CHAR:   types.Typ[types.UntypedRune],
+	token.STRING: types.Typ[types.UntypedString],
+}
+
+// constType returns type of constant used by ident, if types are known.
+func (r *rewriter) constType(ident *ast.Ident) types.Type {
+	if r.info == nil {
+		return nil
+	}
+
+	c, ok := r.info.Uses[ident].(*types.Const)
+	if !ok {
+		return nil
+	}
+	return c.Type()
+}
+
+// untyped returns type of n if it is untyped constant expression, or shift of
+// such, or nil. Without types only literals are known to be constants.
+func (r *rewriter) untyped(n ast.Expr) types.Type {
+	var typ types.Type
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		typ = _literalTypes[n.Kind]
+	case *ast.Ident:
+		typ = r.constType(n)
+	case *ast.SelectorExpr:
+		typ = r.constType(n.Sel)
+	case *ast.ParenExpr:
+		typ = r.untyped(n.X)
+	case *ast.UnaryExpr:
+		if n.Op != token.AND && n.Op != token.ARROW {
+			typ = r.untyped(n.X)
+		}
+	case *ast.BinaryExpr:
+		x, y := r.untyped(n.X), r.untyped(n.Y)
+		switch {
+		case n.Op == token.SHL || n.Op == token.SHR:
+			typ = x // shift of untyped constant gets type from context even if not constant
+		case x == nil || y == nil:
+		case n.Op == token.EQL || n.Op == token.NEQ || n.Op == token.LSS || n.Op == token.LEQ ||
+			n.Op == token.GTR || n.Op == token.GEQ || n.Op == token.LAND || n.Op == token.LOR:
+			typ = types.Typ[types.UntypedBool]
+		case x.(*types.Basic).Kind() > y.(*types.Basic).Kind(): //nolint:forcetypeassert // untyped types are basic
+			typ = x // e.g. 1 + 2.5 is untyped float
+		default:
+			typ = y
+		}
+	}
+
+	if basic, ok := typ.(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 {
+		return basic
+	}
+	return nil
+}
+
+// wrappable returns true if n keeps its type when wrapped, which is not so for
+// untyped constant getting type from context: wrapped, it gets its default
+// type instead, so e.g. f == -1 with float64 f does not compile.
+func (r *rewriter) wrappable(n ast.Expr) bool {
+	typ := r.untyped(n)
+	if typ == nil {
+		return true
+	}
+	if r.info == nil {
+		return false
+	}
+
+	tv, ok := r.info.Types[n]
+	if !ok {
+		return false
+	}
+	if basic, ok := tv.Type.(*types.Basic); ok && basic.Info()&types.IsUntyped != 0 {
+		// constant stays untyped, e.g. in 2+2 == 5, so default type works
+		// unless its kind is changed by other operand, e.g. in 1 == 1.5
+		return types.Identical(basic, typ) && tv.Value != nil && representable(tv.Value, basic)
+	}
+	return types.Identical(tv.Type, types.Default(typ))
+}
+
+// representable returns true if constant value v of untyped type typ is
+// representable by default type of typ.
+func representable(v constant.Value, typ *types.Basic) bool {
+	switch typ.Kind() {
+	case types.UntypedInt, types.UntypedRune:
+		bits := strconv.IntSize
+		if typ.Kind() == types.UntypedRune {
+			bits = 32
+		}
+		i, exact := constant.Int64Val(constant.ToInt(v))
+		return exact && (i>>(bits-1) == 0 || i>>(bits-1) == -1)
+	case types.UntypedFloat:
+		f, _ := constant.Float64Val(v)
+		return !math.IsInf(f, 0)
+	case types.UntypedComplex:
+		re, _ := constant.Float64Val(constant.Real(v))
+		im, _ := constant.Float64Val(constant.Imag(v))
+		return !math.IsInf(re, 0) && !math.IsInf(im, 0)
+	default:
+		return true
+	}
+}
+
+// _equalFuncs are functions comparing their two arguments for equality.
+var _equalFuncs = map[string]bool{
+	"reflect.DeepEqual": true,
+	"slices.Equal":      true,
+	"maps.Equal":        true,
+	"bytes.Equal":       true,
+}
+
+// comparison returns equality comparison deciding predicate: == or !=
+// operator or call of equality function, possibly negated.
+func (r *rewriter) comparison(predicate ast.Expr) ast.Expr {
+	n := ast.Unparen(predicate)
+	if not, ok := n.(*ast.UnaryExpr); ok && not.Op == token.NOT {
+		n = ast.Unparen(not.X)
+	}
+
+	switch n := n.(type) {
+	case *ast.BinaryExpr:
+		if n.Op == token.EQL || n.Op == token.NEQ {
+			return n
+		}
+	case *ast.CallExpr:
+		sel, ok := n.Fun.(*ast.SelectorExpr)
+		if !ok || len(n.Args) != 2 {
+			return nil
+		}
+
+		if ident, ok := sel.X.(*ast.Ident); ok && _equalFuncs[r.packages[ident.Name]+"."+sel.Sel.Name] {
+			return n
+		}
+	}
+	return nil
+}
+
+// stringLit returns dump of n if it is string literal of string type.
+func (r *rewriter) stringLit(n ast.Expr) ast.Expr {
+	if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING && r.wrappable(lit) {
+		return r.dump(data(), lit, lit.Pos())
+	}
+	return n
+}
+
+// compared records comparison n of operands x and y, which are already
+// rewritten, if values of both are captured. Spans are taken before operands
+// are rewritten.
+func (r *rewriter) compared(n ast.Expr, pos token.Pos, x, y ast.Expr, xStart, xEnd, yStart, yEnd ast.Expr) {
+	xPos, okX := r.dumpPos(x)
+	yPos, okY := r.dumpPos(y)
+	if !okX || !okY {
+		return
+	}
+
+	top := "0"
+	if n == r.top {
+		top = "1"
+	}
+	r.equal = append(r.equal, row(r.position(pos), xPos, yPos, xStart, xEnd, yStart, yEnd, &ast.BasicLit{Kind: token.INT, Value: top}))
+}
+
+// _builtinTypes are predeclared identifiers which are not values.
+var _builtinTypes = map[string]bool{
+	"any": true, "bool": true, "byte": true, "comparable": true, "error": true, "rune": true, "string": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// data returns assert data variable.
+func data() ast.Expr {
+	return ast.NewIdent("zzz")
+}
+
+// position returns literal of pos relative to predicate start.
+func (r *rewriter) position(pos token.Pos) *ast.BasicLit {
+	return &ast.BasicLit{
+		Kind:  token.INT,
+		Value: strconv.Itoa(int(pos - r.offset)),
+	}
+}
+
+// internal returns call of SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__
+// method name capturing value of n at pos into data.
+func (r *rewriter) internal(name string, data, n ast.Expr, pos token.Pos) ast.Expr {
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   r.internals,
+			Sel: ast.NewIdent(name),
+		},
+		Args: []ast.Expr{data, r.position(pos), n},
+	}
+}
+
+// dump captures value of n at pos after it is evaluated and returns the value.
+// data is evaluated before n, so captures made by it come first.
+func (r *rewriter) dump(data, n ast.Expr, pos token.Pos) ast.Expr {
+	return r.internal("ZZZAdd", data, n, pos)
+}
+
+// row returns literal of table row with elided type.
+func row(elts ...ast.Expr) ast.Expr {
+	return &ast.CompositeLit{Elts: elts}
+}
+
+// table returns literal of [][n]int slice of rows, or nil if there are none.
+func table(n int, rows []ast.Expr) ast.Expr {
+	if len(rows) == 0 {
+		return ast.NewIdent("nil")
+	}
+
+	return &ast.CompositeLit{
+		Type: &ast.ArrayType{Elt: &ast.ArrayType{
+			Len: &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(n)},
+			Elt: ast.NewIdent("int"),
+		}},
+		Elts: rows,
+	}
+}
+
+// dumpPos returns position literal of value captured by n if n is dump.
+func (r *rewriter) dumpPos(n ast.Expr) (ast.Expr, bool) {
+	for {
+		paren, ok := n.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		n = paren.X
+	}
+
+	call, ok := n.(*ast.CallExpr)
+	if !ok {
+		return nil, false
+	}
+
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.X != r.internals || sel.Sel.Name != "ZZZAdd" {
+		return nil, false
+	}
+
+	return call.Args[1], true
+}
+
+// isPath returns true if n is variable or chain of field selectors of variable,
+// that is expression which can be evaluated more than once without side effects
+// and may be addressable.
+func (r *rewriter) isPath(n ast.Expr) bool {
+	switch n := n.(type) {
+	case *ast.Ident:
+		return r.packages[n.Name] == ""
+	case *ast.SelectorExpr:
+		if ident, ok := n.X.(*ast.Ident); ok && r.packages[ident.Name] != "" {
+			return true // package level variable
+		}
+		return r.isPath(n.X)
+	case *ast.ParenExpr:
+		return r.isPath(n.X)
+	default:
+		return false
+	}
+}
+
+// along returns data capturing values of path and its prefixes, in source
+// order. Path itself is not replaced, so that its addressability and method
+// sets are kept.
+func (r *rewriter) along(path, data ast.Expr) ast.Expr {
+	switch n := path.(type) {
+	case *ast.Ident:
+		if r.packages[n.Name] != "" || n.Name == "nil" || n.Name == "true" || n.Name == "false" {
+			return data
+		}
+		return r.internal("ZZZAddBefore", data, n, n.Pos())
+	case *ast.SelectorExpr:
+		if ident, ok := n.X.(*ast.Ident); ok && r.packages[ident.Name] != "" {
+			return r.internal("ZZZAddBefore", data, n, n.Sel.Pos())
+		}
+		return r.internal("ZZZAddBefore", r.along(n.X, data), n, n.Sel.Pos())
+	case *ast.ParenExpr:
+		return r.along(n.X, data)
+	default:
+		return data
+	}
+}
+
+// keep rewrites operand *x, which may need to stay addressable, so it is not
+// replaced with its copy: values of variables are captured by returned data,
+// and only parts not affecting addressability are rewritten in place.
+func (r *rewriter) keep(x *ast.Expr, data ast.Expr) ast.Expr {
+	if r.isPath(*x) {
+		return r.along(*x, data)
+	}
+
+	switch e := (*x).(type) {
+	case *ast.CompositeLit:
+		r.compositeLit(e)
+	case *ast.IndexExpr:
+		e.Index = r.expr(e.Index)
+		return r.keep(&e.X, data)
+	case *ast.SelectorExpr:
+		return r.keep(&e.X, data)
+	case *ast.StarExpr:
+		e.X = r.expr(e.X)
+	case *ast.ParenExpr:
+		return r.keep(&e.X, data)
+	default: // not addressable anyway
+		*x = r.expr(e)
+	}
+	return data
+}
+
+// isType returns true if n is type expression for sure.
+func isType(n ast.Expr) bool {
+	switch n := n.(type) {
+	case *ast.ArrayType, *ast.StructType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.ChanType, *ast.Ellipsis:
+		return true
+	case *ast.Ident:
+		return _builtinTypes[n.Name]
+	case *ast.StarExpr:
+		return isType(n.X)
+	case *ast.ParenExpr:
+		return isType(n.X)
+	default:
+		return false
+	}
+}
+
+// compositeLit rewrites elements of composite literal. Keys are rewritten only
+// for map literals, since otherwise they are field names or constant indices.
+func (r *rewriter) compositeLit(n *ast.CompositeLit) {
+	_, isMap := n.Type.(*ast.MapType)
+	for i, e := range n.Elts {
+		switch e := e.(type) {
+		case *ast.KeyValueExpr:
+			if isMap {
+				e.Key = r.elem(e.Key)
+			}
+			e.Value = r.elem(e.Value)
+		default:
+			n.Elts[i] = r.elem(e)
+		}
+	}
+}
+
+// elem rewrites composite literal element, which may be composite literal
+// with elided type that cannot be wrapped.
+func (r *rewriter) elem(n ast.Expr) ast.Expr {
+	switch e := n.(type) {
+	case *ast.CompositeLit:
+		if e.Type == nil {
+			r.compositeLit(e)
+			return e
+		}
+	case *ast.UnaryExpr:
+		if lit, ok := e.X.(*ast.CompositeLit); ok && e.Op == token.AND && lit.Type == nil {
+			r.compositeLit(lit)
+			return e
+		}
+	}
+	return r.expr(n)
+}
+
+func (r *rewriter) expr(n ast.Expr) ast.Expr {
+	if n != nil && !r.wrappable(n) {
+		return n
+	}
+
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case *ast.BadExpr, *ast.BasicLit, *ast.FuncLit, *ast.Ellipsis,
+		*ast.ArrayType, *ast.StructType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.ChanType:
+		return n
+	case *ast.IndexListExpr: // generic function instantiation
+		return n
+	case *ast.Ident:
+		if _builtinTypes[n.Name] || r.packages[n.Name] != "" || n.Name == "nil" || n.Name == "true" || n.Name == "false" {
+			return n
+		}
+		return r.dump(data(), n, n.Pos())
+	case *ast.CompositeLit:
+		r.compositeLit(n)
+		return r.dump(data(), n, n.Pos())
+	case *ast.KeyValueExpr:
+		n.Value = r.expr(n.Value)
+		return n
+	case *ast.SelectorExpr:
+		if ident, ok := n.X.(*ast.Ident); ok && r.packages[ident.Name] != "" {
+			return r.dump(data(), n, n.Sel.Pos())
+		}
+		// method values and fields of addressable operands are evaluated on the operand itself
+		return r.dump(r.keep(&n.X, data()), n, n.Sel.Pos())
+	case *ast.ParenExpr:
+		n.X = r.expr(n.X)
+		return n
+	case *ast.SliceExpr:
+		n.Low = r.expr(n.Low)
+		n.High = r.expr(n.High)
+		n.Max = r.expr(n.Max)
+		// slicing array requires it to be addressable
+		return r.dump(r.keep(&n.X, data()), n, n.Lbrack)
+	case *ast.IndexExpr:
+		n.Index = r.expr(n.Index)
+		n.X = r.expr(n.X)
+		return r.dump(data(), n, n.Lbrack)
+	case *ast.TypeAssertExpr:
+		n.X = r.expr(n.X)
+		return r.dump(data(), n, n.Lparen)
+	case *ast.UnaryExpr:
+		if n.Op == token.AND {
+			return r.dump(r.keep(&n.X, data()), n, n.OpPos)
+		}
+		n.X = r.expr(n.X)
+		return r.dump(data(), n, n.OpPos)
+	case *ast.BinaryExpr:
+		// right operand of && and || stays inside of the operator, so it is
+		// evaluated only when needed
+		xStart, xEnd := r.position(n.X.Pos()), r.position(n.X.End()) // before operands are rewritten
+		yStart, yEnd := r.position(n.Y.Pos()), r.position(n.Y.End())
+		n.X = r.expr(n.X)
+		n.Y = r.expr(n.Y)
+		switch {
+		case n.Op == token.LAND || n.Op == token.LOR:
+			if pos, ok := r.dumpPos(n.Y); ok {
+				r.lazy = append(r.lazy, row(pos, yStart, yEnd))
+			}
+		case n.Op == token.EQL || n == r.top:
+			if n == r.top {
+				// string literals are captured to be diffed
+				n.X, n.Y = r.stringLit(n.X), r.stringLit(n.Y)
+			}
+			r.compared(n, n.OpPos, n.X, n.Y, xStart, xEnd, yStart, yEnd)
+		}
+		return r.dump(data(), n, n.OpPos)
+	case *ast.CallExpr:
+		pos := n.Pos() // before receiver and arguments are rewritten
+		var spans []ast.Expr
+		if n == r.top {
+			spans = []ast.Expr{
+				r.position(n.Args[0].Pos()), r.position(n.Args[0].End()),
+				r.position(n.Args[1].Pos()), r.position(n.Args[1].End()),
+			}
+		}
+		args := n.Args
+		if ident, ok := n.Fun.(*ast.Ident); ok && (ident.Name == "new" || ident.Name == "make") && len(args) > 0 {
+			args = args[1:] // type argument
+		}
+		for i, e := range args {
+			if !isType(e) {
+				args[i] = r.expr(e)
+			}
+		}
+		if n == r.top {
+			r.compared(n, pos, n.Args[0], n.Args[1], spans[0], spans[1], spans[2], spans[3])
+		}
+		if sel, ok := n.Fun.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); !ok || r.packages[ident.Name] == "" {
+				// method receivers are captured without being copied
+				return r.dump(r.keep(&sel.X, data()), n, sel.Sel.Pos())
+			}
+		}
+		return r.dump(data(), n, pos)
+	case *ast.StarExpr:
+		n.X = r.expr(n.X)
+		return r.dump(data(), n, n.Star)
+	default:
+		return n
+	}
+}
+
+// packageNames returns import paths of packages imported in file by names
+// they are referred by.
+func packageNames(f *ast.File) map[string]string {
+	res := map[string]string{}
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+
+		if spec.Name != nil {
+			res[spec.Name.Name] = importPath
+			continue
+		}
+
+		// guess package name from import path, e.g. gopkg.in/yaml.v3 or github.com/a/go-b/v2
+		elems := strings.Split(importPath, "/")
+		name := elems[len(elems)-1]
+		if len(elems) > 1 && isMajorVersion(name) {
+			name = elems[len(elems)-2]
+		}
+		name, _, _ = strings.Cut(name, ".")
+		name = strings.TrimPrefix(name, "go-")
+		res[strings.ReplaceAll(name, "-", "")] = importPath
+	}
+	return res
+}
+
+func isMajorVersion(s string) bool {
+	n, ok := strings.CutPrefix(s, "v")
+	_, err := strconv.Atoi(n)
+	return ok && err == nil
+}
+
+// edit is replacement of source span [pos, end) with node.
+type edit struct {
+	pos, end token.Pos
+	node     ast.Node
+}
+
+// assertNames returns names by which assert package is imported in file,
+// "." for dot imports.
+func assertNames(f *ast.File) map[string]bool {
+	res := map[string]bool{}
+	for _, spec := range f.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err != nil || importPath != _pkgPath {
+			continue
+		}
+
+		switch {
+		case spec.Name == nil:
+			res["assert"] = true
+		case spec.Name.Name != "_":
+			res[spec.Name.Name] = true
+		}
+	}
+	return res
+}
+
+// callees finds references to Assert and Require functions of assert package.
+type callees struct {
+	// info are types of expressions, if known
+	info *types.Info
+	// names are names of assert package imports in file, see assertNames
+	names map[string]bool
+}
+
+// isAssertFunc reports whether ident refers to Assert or Require function of
+// assert package. Imported names are used if types of ident are not known.
+func (c callees) isAssertFunc(ident *ast.Ident, imported bool) bool {
+	if ident.Name != "Assert" && ident.Name != "Require" {
+		return false
+	}
+
+	if c.info != nil {
+		if obj := c.info.Uses[ident]; obj != nil {
+			fn, ok := obj.(*types.Func)
+			return ok && fn.Pkg() != nil && fn.Pkg().Path() == _pkgPath &&
+				fn.Signature().Recv() == nil
+		}
+	}
+	return imported
+}
+
+// callee returns name of Assert or Require function referred by n and
+// expression referring to SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__
+// the same way, e.g. through aliased or dot import. Empty name is returned if
+// n refers to neither of them.
+func (c callees) callee(n ast.Expr) (name string, internals ast.Expr) {
+	switch n := n.(type) {
+	case *ast.SelectorExpr:
+		x, ok := n.X.(*ast.Ident)
+		if !ok || !c.isAssertFunc(n.Sel, c.names[x.Name]) {
+			return "", nil
+		}
+		return n.Sel.Name, &ast.SelectorExpr{X: ast.NewIdent(x.Name), Sel: ast.NewIdent(_internals)}
+	case *ast.Ident:
+		if !c.isAssertFunc(n, c.names["."]) {
+			return "", nil
+		}
+		return n.Name, ast.NewIdent(_internals)
+	default:
+		return "", nil
+	}
+}
+
+// assertCall returns Assert(tb, <predicate>, <msgAndArgs>...) or Require call
+// made as statement n, which can be rewritten, with its callee.
+func (c callees) assertCall(n ast.Node) (call *ast.CallExpr, name string, internals ast.Expr) {
+	stmt, ok := n.(*ast.ExprStmt)
+	if !ok {
+		return nil, "", nil
+	}
+
+	call, ok = stmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) < 2 {
+		return nil, "", nil
+	}
+
+	name, internals = c.callee(call.Fun)
+	if name == "" {
+		return nil, "", nil
+	}
+	return call, name, internals
+}
+
+// check returns error listing uses of Assert and Require which are not
+// statement calls, e.g. deferred calls or function values, as they cannot be
+// rewritten and would fail at run time.
+func (c callees) check(filename string, fset *token.FileSet, root *ast.File) error {
+	calls := map[ast.Expr]bool{}
+	ast.Inspect(root, func(n ast.Node) bool {
+		if call, _, _ := c.assertCall(n); call != nil {
+			calls[call.Fun] = true
+		}
+		return true
+	})
+
+	var errs []error
+	var inspect func(n ast.Node) bool
+	inspect = func(n ast.Node) bool {
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			return true
+		}
+
+		if name, _ := c.callee(expr); name != "" && !calls[expr] {
+			pos := fset.Position(expr.Pos())
+			pos.Filename = filename
+			errs = append(errs, fmt.Errorf("%s: %s can only be called as statement with predicate expression", pos, name))
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			// selected name is not reference on its own
+			ast.Inspect(sel.X, inspect)
+			return false
+		}
+		return true
+	}
+	ast.Inspect(root, inspect)
+	return errors.Join(errs...)
+}
+
+// Rewrite rewrites Assert and Require calls made as statements anywhere in
+// file root parsed from src. It reports whether file was changed, error is
+// returned if they are used otherwise.
+func Rewrite(fset *token.FileSet, root *ast.File, src []byte) (bool, error) {
+	edits, err := rewrite(fset.File(root.Pos()).Name(), fset, root, src, nil)
+	return len(edits) > 0, err
+}
+
+// rewrite rewrites file root as Rewrite does, using types of expressions from
+// info if it is not nil, and returns made replacements in source order.
+// Replacements nested in other ones are omitted. Errors refer to positions in
+// filename.
+func rewrite(filename string, fset *token.FileSet, root *ast.File, src []byte, info *types.Info) ([]edit, error) {
+	c := callees{info: info, names: assertNames(root)}
+	if err := c.check(filename, fset, root); err != nil {
+		return nil, err
+	}
+
+	var edits []edit
+	replace := func(c *astutil.Cursor, node ast.Node) {
+		n := c.Node()
+		// nodes are replaced bottom-up, so nested replacements come first
+		edits = slices.DeleteFunc(edits, func(e edit) bool {
+			return n.Pos() <= e.pos && e.end <= n.End()
+		})
+		edits = append(edits, edit{n.Pos(), n.End(), node})
+		c.Replace(node)
+	}
+	packages := packageNames(root)
+	astutil.Apply(root, nil, func(cursor *astutil.Cursor) bool {
+		call, name, internals := c.assertCall(cursor.Node())
+		if call == nil {
+			return true
+		}
+
+		predicate := call.Args[1]
+		exprStr := string(src[fset.Position(predicate.Pos()).Offset:fset.Position(predicate.End()).Offset])
+		r := rewriter{offset: predicate.Pos(), packages: packages, lazy: nil, equal: nil, top: nil, internals: internals, info: info}
+		r.top = r.comparison(predicate)
+		fused := r.expr(predicate)
+		replace(cursor, &ast.BlockStmt{
+			List: []ast.Stmt{
+				&ast.AssignStmt{ // zzz := assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZNew("2+2 == 5", <lazy operands>, <comparisons>)
+					Tok: token.DEFINE,
+					Lhs: []ast.Expr{&ast.Ident{Name: "zzz"}},
+					Rhs: []ast.Expr{
+						&ast.CallExpr{
+							Fun: &ast.SelectorExpr{
+								X:   internals,
+								Sel: ast.NewIdent("ZZZNew"),
+							},
+							Args: []ast.Expr{
+								&ast.BasicLit{
+									Kind:  token.STRING,
+									Value: strconv.Quote(exprStr),
+								},
+								table(3, r.lazy),
+								table(8, r.equal),
+							},
+						},
+					},
+				},
+				&ast.ExprStmt{ // assert.ZZZAssert(tb, zzz, <fused predicate expression>, <msgAndArgs>...)
+					X: &ast.CallExpr{
+						Fun: &ast.SelectorExpr{
+							X:   internals,
+							Sel: ast.NewIdent("ZZZ" + name),
+						},
+						Args:     append([]ast.Expr{call.Args[0], data(), fused}, call.Args[2:]...),
+						Ellipsis: call.Ellipsis,
+					},
+				},
+			},
+		})
+
+		return true
+	})
+	slices.SortFunc(edits, func(a, b edit) int {
+		return int(a.pos - b.pos)
+	})
+	return edits, nil
+}
+
+// lineDirective returns /*line*/ directive setting position of following
+// source to pos.
+func lineDirective(pos token.Position) string {
+	return "/*line " + pos.Filename + ":" + strconv.Itoa(pos.Line) + ":" + strconv.Itoa(pos.Column) + "*/"
+}
+
+// RewriteSource rewrites source src of test file filename as Rewrite does and
+// returns rewritten source. ok is false if there is nothing to rewrite.
+// Types of expressions are not known, so untyped named constants are captured
+// with their default type, see RewriteFile.
+func RewriteSource(filename string, src []byte) (res []byte, ok bool, err error) {
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, false, err //nolint:wrapcheck
+	}
+
+	return RewriteFile(filename, fset, root, src, nil)
+}
+
+// RewriteFile rewrites file root parsed from source src of test file filename
+// as Rewrite does and returns rewritten source. ok is false if there is
+// nothing to rewrite. info are types of expressions of file package, if known,
+// used to leave untyped constants as they are and to find Assert and Require
+// calls.
+// Rewritten source is original one with rewritten statements spliced in, and
+// line directives map positions back to filename, so that compiler errors,
+// stack traces and failures point to original lines.
+func RewriteFile(filename string, fset *token.FileSet, root *ast.File, src []byte, info *types.Info) (res []byte, ok bool, err error) {
+	edits, err := rewrite(filename, fset, root, src, info)
+	if err != nil || len(edits) == 0 {
+		return nil, false, err
+	}
+
+	var buf bytes.Buffer
+	// on its own line, so that build constraints stay at line start
+	buf.WriteString("//line " + filename + ":1\n")
+	prev := 0
+	for _, e := range edits {
+		pos, end := fset.Position(e.pos), fset.Position(e.end)
+		pos.Filename, end.Filename = filename, filename
+		buf.Write(src[prev:pos.Offset])
+		if block, ok := e.node.(*ast.BlockStmt); ok {
+			// keep every statement on the line of replaced one
+			buf.WriteString("{")
+			for i, stmt := range block.List {
+				if i > 0 {
+					buf.WriteString("; ")
+				}
+				buf.WriteString(lineDirective(pos))
+				buf.WriteString(sprintCode(stmt))
+			}
+			buf.WriteString("}")
+		} else {
+			buf.WriteString(sprintCode(e.node))
+		}
+		buf.WriteString(lineDirective(end))
+		prev = end.Offset
+	}
+	buf.Write(src[prev:])
+	return buf.Bytes(), true, nil
+}
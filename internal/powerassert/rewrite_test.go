@@ -0,0 +1,123 @@
+package powerassert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rprtr258/assert/internal/ass"
+)
+
+func TestRewriteSource(t *testing.T) {
+	const src = `package x_test
+
+import (
+	"testing"
+
+	"github.com/rprtr258/assert"
+)
+
+func TestX(t *testing.T) {
+	x := 1
+	assert.Assert(t, x == 2)
+	assert.Require(t, x != 1)
+	assert.Assert(t, x == x || x > 0)
+	assert.Assert(t, x > 1, "x is %d", x)
+	args := []any{x}
+	assert.Require(t, x > 1, args...)
+}
+`
+	got, ok, err := RewriteSource("x_test.go", []byte(src))
+	ass.NoError(t, err)
+	ass.True(t, ok)
+	ass.SContains(t, `ZZZNew("x == 2", nil, nil)`, string(got))
+	ass.SContains(t, `ZZZAssert(t, zzz, assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZAdd(zzz, 2, assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZAdd(zzz, 0, x) == 2))`, string(got))
+	ass.SContains(t, `ZZZRequire(t, zzz,`, string(got))
+	ass.SContains(t, `ZZZNew("x == x || x > 0", [][3]int{{12, 10, 15}}, [][8]int{{2, 0, 5, 0, 1, 5, 6, 0}})`, string(got))
+	ass.SContains(t, `ZZZAdd(zzz, 0, x) > 1), "x is %d", x)`, string(got))
+	ass.SContains(t, `ZZZAdd(zzz, 0, x) > 1), args...)`, string(got))
+	ass.SContainsNot(t, "assert.Assert(", string(got))
+	// positions map back to original lines
+	ass.True(t, strings.HasPrefix(string(got), "//line x_test.go:1\n"+src[:10]))
+	ass.SContains(t, "{/*line x_test.go:11:2*/zzz := ", string(got))
+	ass.SContains(t, "; /*line x_test.go:11:2*/assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZAssert(", string(got))
+	ass.SContains(t, "}/*line x_test.go:11:26*/\n\t{/*line x_test.go:12:2*/", string(got))
+}
+
+func TestRewriteSourceTopComparison(t *testing.T) {
+	const src = `package x_test
+
+import (
+	r "reflect"
+	"testing"
+
+	"github.com/rprtr258/assert"
+)
+
+func TestX(t *testing.T) {
+	x := []int{1}
+	assert.Assert(t, !r.DeepEqual(x, x))
+	assert.Assert(t, len(x) != 1)
+}
+`
+	got, ok, err := RewriteSource("x_test.go", []byte(src))
+	ass.NoError(t, err)
+	ass.True(t, ok)
+	ass.SContains(t, `ZZZNew("!r.DeepEqual(x, x)", nil, [][8]int{{1, 13, 16, 13, 14, 16, 17, 1}})`, string(got))
+	ass.SContains(t, `ZZZNew("len(x) != 1", nil, nil)`, string(got))
+}
+
+func TestRewriteSourceNothingToRewrite(t *testing.T) {
+	const src = `package x_test
+
+import "testing"
+
+func TestX(t *testing.T) {}
+`
+	_, ok, err := RewriteSource("x_test.go", []byte(src))
+	ass.NoError(t, err)
+	ass.False(t, ok)
+
+	_, _, err = RewriteSource("x_test.go", []byte("package"))
+	ass.NotEqual(t, nil, err)
+}
+
+func TestRewriteSourceImportNames(t *testing.T) {
+	for name, test := range map[string]struct {
+		imp, call, internals string
+	}{
+		"aliased": {`a "github.com/rprtr258/assert"`, "a.Assert", "a.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__"},
+		"dot":     {`. "github.com/rprtr258/assert"`, "Assert", "SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			src := "package x_test\n\nimport (\n\t\"testing\"\n\n\t" + test.imp + "\n)\n\n" +
+				"func TestX(t *testing.T) {\n\t" + test.call + "(t, 1 == 2)\n}\n"
+			got, ok, err := RewriteSource("x_test.go", []byte(src))
+			ass.NoError(t, err)
+			ass.True(t, ok)
+			ass.SContains(t, "/*line x_test.go:10:2*/"+test.internals+".ZZZAssert(t, zzz, ", string(got))
+		})
+	}
+}
+
+func TestRewriteSourceNotCall(t *testing.T) {
+	const src = `package x_test
+
+import (
+	"testing"
+
+	a "github.com/rprtr258/assert"
+)
+
+func TestX(t *testing.T) {
+	a.Assert(t, true)
+	defer a.Assert(t, true)
+	go a.Require(t, true)
+	f := a.Assert
+	f(t, true)
+}
+`
+	_, _, err := RewriteSource("x_test.go", []byte(src))
+	ass.Equal(t, "x_test.go:11:8: Assert can only be called as statement with predicate expression\n"+
+		"x_test.go:12:5: Require can only be called as statement with predicate expression\n"+
+		"x_test.go:13:7: Assert can only be called as statement with predicate expression", err.Error())
+}
@@ -0,0 +1,91 @@
+// Printing of channels, functions and unsafe pointers. Everything in this file should be private.
+package pp
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"unsafe"
+)
+
+// hchan mirrors leading fields of runtime.hchan in runtime/chan.go, checked
+// against Go 1.27, recheck it when updating Go version. Layout is not
+// guaranteed, so chanClosed checks fields it can verify and printChan falls
+// back to length and capacity only if they do not match.
+type hchan struct {
+	qcount   uint
+	dataqsiz uint
+	buf      unsafe.Pointer
+	elemsize uint16
+	closed   uint32
+}
+
+// chanClosed reports whether channel ch is closed. ok is false if channel
+// layout does not match expected one, so state cannot be detected.
+func chanClosed(ch reflect.Value) (closed, ok bool) {
+	h := (*hchan)(ch.UnsafePointer())
+	if h.dataqsiz != uint(ch.Cap()) || uintptr(h.elemsize) != ch.Type().Elem().Size() {
+		return false, false
+	}
+
+	return atomic.LoadUint32(&h.closed) != 0, true
+}
+
+// printChan prints channel address, length, capacity and whether it is
+// closed, e.g. (chan int)(0xc000010000, len: 1, cap: 2, closed).
+func (p *printer) printChan() {
+	if p.value.IsNil() {
+		p.printf("(%s)(%s)", p.colorizeType(p.value.Type()), p.nil())
+		return
+	}
+
+	p.printf(
+		"(%s)(%s, len: %s, cap: %s",
+		p.colorizeType(p.value.Type()),
+		p.pointerAddr(),
+		p.colorize(strconv.Itoa(p.value.Len()), p.opts.Scheme.ObjectLength),
+		p.colorize(strconv.Itoa(p.value.Cap()), p.opts.Scheme.ObjectLength),
+	)
+	if closed, ok := chanClosed(p.value); ok {
+		if closed {
+			p.print(", closed")
+		} else {
+			p.print(", open")
+		}
+	}
+	p.print(")")
+}
+
+// printFunc prints function name and its definition place,
+// e.g. func(int) string {pkg.handler at /path/to/file.go:12}.
+func (p *printer) printFunc() {
+	if p.value.IsNil() {
+		p.printf("(%s)(%s)", p.colorizeType(p.value.Type()), p.nil())
+		return
+	}
+
+	fn := runtime.FuncForPC(p.value.Pointer())
+	if fn == nil {
+		p.print(p.colorizeType(p.value.Type()) + " {" + p.pointerAddr() + "}")
+		return
+	}
+
+	file, line := fn.FileLine(fn.Entry())
+	p.printf(
+		"%s {%s at %s:%s}",
+		p.colorizeType(p.value.Type()),
+		p.colorize(fn.Name(), p.opts.Scheme.StructName),
+		file,
+		p.colorize(strconv.Itoa(line), p.opts.Scheme.Integer),
+	)
+}
+
+func (p *printer) printUnsafePointer() {
+	if p.value.IsNil() {
+		p.print(p.colorizeType(p.value.Type()) + "(" + p.nil() + ")")
+		return
+	}
+
+	p.print(p.colorizeType(p.value.Type()) + "(" + p.pointerAddr() + ")")
+}
@@ -0,0 +1,70 @@
+// Folding of long strings and large collections. Everything in this file should be private.
+package pp
+
+import (
+	"cmp"
+	"slices"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// span is half-open range [lo, hi) of kept elements or bytes.
+type span struct{ lo, hi int }
+
+// foldSpans returns ranges of n elements kept when folding: context elements
+// from the head, the tail and around focus. Single span of all elements is
+// returned if n does not exceed threshold.
+func foldSpans(n, threshold, context, focus int) []span {
+	if n <= threshold {
+		return []span{{0, n}}
+	}
+
+	spans := []span{
+		{0, context},
+		{max(focus-context, 0), min(focus+context, n)},
+		{n - context, n},
+	}
+	slices.SortFunc(spans, func(a, b span) int {
+		return cmp.Compare(a.lo, b.lo)
+	})
+
+	res := spans[:1]
+	for _, s := range spans[1:] {
+		last := &res[len(res)-1]
+		if s.lo <= last.hi {
+			last.hi = max(last.hi, s.hi)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// foldStringSpans is foldSpans for bytes of s, with spans bounds moved to
+// rune starts, so that no rune is cut.
+func foldStringSpans(s string, threshold, context, focus int) []span {
+	spans := foldSpans(len(s), threshold, context, focus)
+	if len(spans) == 1 {
+		return spans
+	}
+
+	runeStart := func(i int) int {
+		for i < len(s) && !utf8.RuneStart(s[i]) {
+			i++
+		}
+		return i
+	}
+	for i := range spans {
+		spans[i] = span{runeStart(spans[i].lo), runeStart(spans[i].hi)}
+	}
+	return spans
+}
+
+var _countPrinter = message.NewPrinter(language.English)
+
+// formatCount formats n with thousands separators.
+func formatCount(n int) string {
+	return _countPrinter.Sprintf("%d", n)
+}
@@ -0,0 +1,47 @@
+// Registry of custom per-type formatters
+package pp
+
+import (
+	"reflect"
+	"sync"
+	_ "unsafe" // to use go:linkname
+)
+
+//go:linkname valueInterface reflect.valueInterface
+func valueInterface(v reflect.Value, safe bool) any
+
+var (
+	_formattersMu sync.RWMutex
+	_formatters   = map[reflect.Type]func(reflect.Value) string{}
+)
+
+// RegisterFormatter makes all printers use format for printing values of type T.
+// Registering formatter for same type again replaces previous one.
+func RegisterFormatter[T any](format func(T) string) {
+	_formattersMu.Lock()
+	defer _formattersMu.Unlock()
+
+	_formatters[reflect.TypeFor[T]()] = func(v reflect.Value) string {
+		// unexported fields are formatted too
+		return format(valueInterface(v, false).(T)) //nolint:forcetypeassert // type is checked by registry lookup
+	}
+}
+
+func lookupFormatter(typ reflect.Type) (func(reflect.Value) string, bool) {
+	_formattersMu.RLock()
+	defer _formattersMu.RUnlock()
+
+	format, ok := _formatters[typ]
+	return format, ok
+}
+
+// callFormatter returns result of format applied to v, panics are recovered.
+func callFormatter(format func(reflect.Value) string, v reflect.Value) (text string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			text, ok = "", false
+		}
+	}()
+
+	return format(v), true
+}
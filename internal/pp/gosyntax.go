@@ -0,0 +1,355 @@
+// Printing values as Go source code. Everything in this file should be private.
+package pp
+
+import (
+	"go/format"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type goPrinter struct {
+	strings.Builder
+	visited map[uintptr]bool
+	// pkg is import path of package where expression is used, if known
+	pkg string
+}
+
+// formatGo returns value as gofmt-formatted Go expression used in package
+// pkg, if it is not empty.
+func formatGo(pkg string, object any) string {
+	p := &goPrinter{visited: map[uintptr]bool{}, pkg: pkg}
+	p.print(reflect.ValueOf(object), false)
+
+	const prefix = "package p\n\nvar _ = "
+	src, err := format.Source([]byte(prefix + p.String() + "\n"))
+	if err != nil {
+		return p.String()
+	}
+	return strings.TrimSpace(string(src[len(prefix):]))
+}
+
+// typeString returns type as Go source, types declared in package where
+// expression is used are not qualified.
+func (p *goPrinter) typeString(typ reflect.Type) string {
+	if typ.Name() != "" {
+		if typ.PkgPath() != "" && typ.PkgPath() == p.pkg {
+			return typ.Name()
+		}
+		return typ.String()
+	}
+
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return "*" + p.typeString(typ.Elem())
+	case reflect.Slice:
+		return "[]" + p.typeString(typ.Elem())
+	case reflect.Array:
+		return "[" + strconv.Itoa(typ.Len()) + "]" + p.typeString(typ.Elem())
+	case reflect.Map:
+		return "map[" + p.typeString(typ.Key()) + "]" + p.typeString(typ.Elem())
+	case reflect.Chan:
+		if dir, ok := strings.CutSuffix(typ.String(), typ.Elem().String()); ok {
+			return dir + p.typeString(typ.Elem())
+		}
+		return typ.String() // e.g. chan (<-chan int)
+	default:
+		return typ.String()
+	}
+}
+
+// typeName returns type name suitable for use in conversions.
+func (p *goPrinter) typeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Func, reflect.Chan:
+		return "(" + p.typeString(typ) + ")"
+	default:
+		return p.typeString(typ)
+	}
+}
+
+// isDefaultType reports whether typ is default type of untyped constants of its kind.
+func isDefaultType(typ reflect.Type) bool {
+	switch typ {
+	case reflect.TypeFor[bool](), reflect.TypeFor[int](), reflect.TypeFor[float64](),
+		reflect.TypeFor[complex128](), reflect.TypeFor[string]():
+		return true
+	default:
+		return false
+	}
+}
+
+// printConst prints constant literal, converting it to value type if it
+// cannot be inferred from context.
+func (p *goPrinter) printConst(v reflect.Value, lit string, typed bool) {
+	if typed || isDefaultType(v.Type()) {
+		p.WriteString(lit)
+		return
+	}
+
+	p.WriteString(p.typeName(v.Type()) + "(" + lit + ")")
+}
+
+// print writes value v as Go expression. typed reports whether type of
+// expression is known from context, e.g. for slice elements, so literals
+// need no conversion.
+func (p *goPrinter) print(v reflect.Value, typed bool) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		p.WriteString("nil")
+	case reflect.Bool:
+		p.printConst(v, strconv.FormatBool(v.Bool()), typed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p.printConst(v, strconv.FormatInt(v.Int(), 10), typed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		p.printConst(v, strconv.FormatUint(v.Uint(), 10), typed)
+	case reflect.Float32, reflect.Float64:
+		p.printFloat(v, typed)
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		bitSize := v.Type().Bits() / 2 //nolint:mnd // two floats
+		p.printConst(v, "complex("+formatFloat(real(c), bitSize)+", "+formatFloat(imag(c), bitSize)+")", typed)
+	case reflect.String:
+		p.printConst(v, strconv.Quote(v.String()), typed)
+	case reflect.Slice:
+		p.printSlice(v, typed)
+	case reflect.Array:
+		p.printElems(v)
+	case reflect.Map:
+		p.printMap(v, typed)
+	case reflect.Struct:
+		p.printStruct(v)
+	case reflect.Interface:
+		if v.IsNil() {
+			// interface values are always slots of known type
+			p.WriteString("nil")
+			return
+		}
+		p.print(v.Elem(), false)
+	case reflect.Ptr:
+		p.printPtr(v, typed)
+	case reflect.Chan:
+		if v.IsNil() {
+			p.printNil(v, typed)
+			return
+		}
+		p.WriteString("make(" + p.typeString(v.Type()) + ", " + strconv.Itoa(v.Cap()) + ")")
+	case reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			p.printNil(v, typed)
+			return
+		}
+		p.WriteString("nil /* " + v.Type().String() + " */")
+	default:
+		p.WriteString("nil /* unsupported " + v.Type().String() + " */")
+	}
+}
+
+func (p *goPrinter) printNil(v reflect.Value, typed bool) {
+	if typed {
+		p.WriteString("nil")
+		return
+	}
+
+	p.WriteString(p.typeName(v.Type()) + "(nil)")
+}
+
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, bitSize)
+	}
+}
+
+func (p *goPrinter) printFloat(v reflect.Value, typed bool) {
+	f := v.Float()
+	lit := formatFloat(f, v.Type().Bits())
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		// function calls results are float64
+		typed = typed || v.Type() == reflect.TypeFor[float64]()
+		if !typed {
+			lit = p.typeName(v.Type()) + "(" + lit + ")"
+		}
+		p.WriteString(lit)
+		return
+	}
+
+	if !strings.ContainsAny(lit, ".e") {
+		// keep it float constant, not integer one
+		lit += ".0"
+	}
+	p.printConst(v, lit, typed)
+}
+
+// isPrintable reports whether b is valid UTF-8 text without control characters.
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *goPrinter) printSlice(v reflect.Value, typed bool) {
+	if v.IsNil() {
+		p.printNil(v, typed)
+		return
+	}
+
+	if v.Type().Elem().Kind() == reflect.Uint8 && v.Len() > 0 && isPrintable(v.Bytes()) {
+		name := p.typeName(v.Type())
+		if v.Type() == reflect.TypeFor[[]byte]() {
+			name = "[]byte" // instead of []uint8
+		}
+		p.WriteString(name + "(" + strconv.Quote(string(v.Bytes())) + ")")
+		return
+	}
+
+	if p.visited[v.Pointer()] {
+		p.WriteString("nil /* cycle */")
+		return
+	}
+	p.visited[v.Pointer()] = true
+	defer delete(p.visited, v.Pointer())
+
+	p.printElems(v)
+}
+
+// printElems prints slice or array as composite literal.
+func (p *goPrinter) printElems(v reflect.Value) {
+	p.WriteString(p.typeString(v.Type()) + "{")
+	if v.Len() > 0 {
+		p.WriteString("\n")
+	}
+	elemTyped := v.Type().Elem().Kind() != reflect.Interface
+	for i := range v.Len() {
+		p.print(v.Index(i), elemTyped)
+		p.WriteString(",\n")
+	}
+	p.WriteString("}")
+}
+
+func (p *goPrinter) printMap(v reflect.Value, typed bool) {
+	if v.IsNil() {
+		p.printNil(v, typed)
+		return
+	}
+
+	if p.visited[v.Pointer()] {
+		p.WriteString("nil /* cycle */")
+		return
+	}
+	p.visited[v.Pointer()] = true
+	defer delete(p.visited, v.Pointer())
+
+	p.WriteString(p.typeString(v.Type()) + "{")
+	if v.Len() > 0 {
+		p.WriteString("\n")
+	}
+	keyTyped := v.Type().Key().Kind() != reflect.Interface
+	elemTyped := v.Type().Elem().Kind() != reflect.Interface
+	sorted := sortMap(v)
+	for i := range sorted.Len() {
+		p.print(sorted.keys[i], keyTyped)
+		p.WriteString(": ")
+		p.print(sorted.values[i], elemTyped)
+		p.WriteString(",\n")
+	}
+	p.WriteString("}")
+}
+
+func (p *goPrinter) printStruct(v reflect.Value) {
+	typ := v.Type()
+	if typ == reflect.TypeFor[time.Time]() {
+		p.printTime(valueInterface(v, false).(time.Time)) //nolint:forcetypeassert // type is checked
+		return
+	}
+
+	p.WriteString(p.typeString(typ) + "{")
+	first := true
+	for i := range v.NumField() {
+		field := v.Field(i)
+		if valueIsZero(field) {
+			continue
+		}
+		if pkg := typ.Field(i).PkgPath; p.pkg != "" && pkg != "" && pkg != p.pkg {
+			continue // unexported field of other package cannot be set
+		}
+
+		if first {
+			p.WriteString("\n")
+			first = false
+		}
+		p.WriteString(typ.Field(i).Name + ": ")
+		p.print(field, field.Kind() != reflect.Interface)
+		p.WriteString(",\n")
+	}
+	p.WriteString("}")
+}
+
+func (p *goPrinter) printTime(tm time.Time) {
+	var loc string
+	switch tm.Location() {
+	case time.UTC:
+		loc = "time.UTC"
+	case time.Local:
+		loc = "time.Local"
+	default:
+		name, offset := tm.Zone()
+		loc = "time.FixedZone(" + strconv.Quote(name) + ", " + strconv.Itoa(offset) + ")"
+	}
+
+	p.WriteString("time.Date(" + strings.Join([]string{
+		strconv.Itoa(tm.Year()),
+		"time." + tm.Month().String(),
+		strconv.Itoa(tm.Day()),
+		strconv.Itoa(tm.Hour()),
+		strconv.Itoa(tm.Minute()),
+		strconv.Itoa(tm.Second()),
+		strconv.Itoa(tm.Nanosecond()),
+		loc,
+	}, ", ") + ")")
+}
+
+func (p *goPrinter) printPtr(v reflect.Value, typed bool) {
+	if v.IsNil() {
+		p.printNil(v, typed)
+		return
+	}
+
+	if p.visited[v.Pointer()] {
+		p.WriteString("nil /* cycle */")
+		return
+	}
+	p.visited[v.Pointer()] = true
+	defer delete(p.visited, v.Pointer())
+
+	elem := v.Elem()
+	if elem.Kind() == reflect.Array ||
+		elem.Kind() == reflect.Struct && elem.Type() != reflect.TypeFor[time.Time]() {
+		// composite literal, its address can be taken
+		p.WriteString("&")
+		p.print(elem, true)
+		return
+	}
+
+	// user is expected to have
+	//	func ptr[T any](v T) *T { return &v }
+	p.WriteString("ptr(")
+	p.print(elem, false)
+	p.WriteString(")")
+}
@@ -0,0 +1,78 @@
+package pp
+
+import (
+	"go/parser"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/assert/internal/ass"
+)
+
+type Named int8
+
+type WithAny struct {
+	A      any
+	P      *int
+	S      *Foo
+	Nested []HogeHoge
+	skip   string
+}
+
+func TestSprintGo(t *testing.T) {
+	n := 5
+	for _, test := range []struct {
+		value any
+		want  string
+	}{
+		{nil, `nil`},
+		{1, `1`},
+		{int8(1), `int8(1)`},
+		{Named(2), `pp.Named(2)`},
+		{3.0, `3.0`},
+		{float32(0.5), `float32(0.5)`},
+		{math.Inf(-1), `math.Inf(-1)`},
+		{complex(1, -2), `complex(1, -2)`},
+		{"a\"b\n", `"a\"b\n"`},
+		{true, `true`},
+		{[]int(nil), `[]int(nil)`},
+		{(*int)(nil), `(*int)(nil)`},
+		{[]int{}, `[]int{}`},
+		{[]byte("hello"), `[]byte("hello")`},
+		{[]byte{0, 1}, "[]uint8{\n\t0,\n\t1,\n}"},
+		{[]any{1, int8(2), "x", nil}, "[]interface{}{\n\t1,\n\tint8(2),\n\t\"x\",\n\tnil,\n}"},
+		{[2]bool{true}, "[2]bool{\n\ttrue,\n\tfalse,\n}"},
+		{map[string]int{"b": 2, "a": 1}, "map[string]int{\n\t\"a\": 1,\n\t\"b\": 2,\n}"},
+		{EmptyStruct{}, `pp.EmptyStruct{}`},
+		{&HogeHoge{Hell: "a", A: Named(1)}, "&pp.HogeHoge{\n\tHell: \"a\",\n\tA:    pp.Named(1),\n}"},
+		{&n, `ptr(5)`},
+		{
+			WithAny{A: 1.5, P: &n, S: &Foo{Bar: 1}, Nested: []HogeHoge{{World: 1}}, skip: "x"},
+			"pp.WithAny{\n\tA: 1.5,\n\tP: ptr(5),\n\tS: &pp.Foo{\n\t\tBar: 1,\n\t},\n\tNested: []pp.HogeHoge{\n\t\tpp.HogeHoge{\n\t\t\tWorld: 1,\n\t\t},\n\t},\n\tskip: \"x\",\n}",
+		},
+		{time.Date(2015, time.February, 14, 22, 15, 0, 0, time.UTC), `time.Date(2015, time.February, 14, 22, 15, 0, 0, time.UTC)`},
+		{c, "pp.Circular{\n\tC: &pp.Circular{\n\t\tC: nil, /* cycle */\n\t},\n}"},
+	} {
+		t.Run(test.want, func(t *testing.T) {
+			got := SprintGo(test.value)
+			ass.Equal(t, test.want, got)
+
+			_, err := parser.ParseExpr(got)
+			ass.NoError(t, err)
+		})
+	}
+}
+
+func TestSprintGoIn(t *testing.T) {
+	v := []WithAny{{A: time.Second, Nested: []HogeHoge{{World: 1}}, skip: "x"}}
+	// declaring package
+	ass.Equal(t,
+		"[]WithAny{\n\tWithAny{\n\t\tA: time.Duration(1000000000),\n\t\tNested: []HogeHoge{\n\t\t\tHogeHoge{\n\t\t\t\tWorld: 1,\n\t\t\t},\n\t\t},\n\t\tskip: \"x\",\n\t},\n}",
+		SprintGoIn(reflect.TypeFor[WithAny]().PkgPath(), v))
+	// other package
+	ass.Equal(t,
+		"[]pp.WithAny{\n\tpp.WithAny{\n\t\tA: time.Duration(1000000000),\n\t\tNested: []pp.HogeHoge{\n\t\t\tpp.HogeHoge{\n\t\t\t\tWorld: 1,\n\t\t\t},\n\t\t},\n\t},\n}",
+		SprintGoIn("example.com/other", v))
+	ass.Equal(t, "map[Named]chan<- Named{}", SprintGoIn(reflect.TypeFor[Named]().PkgPath(), map[Named]chan<- Named{}))
+}
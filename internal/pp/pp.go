@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"reflect"
 	"runtime"
 	"sync"
 
@@ -42,75 +44,156 @@ var defaultScheme = ColorScheme{
 	ObjectLength:    scuf.FgBlue,
 }
 
-// Global variable API
-var (
-	// Default pretty printer. It's public so that you can modify config globally.
-	Default = newPrettyPrinter(3) //nolint:mnd // pp.* => PrettyPrinter.* => formatAll
-	// If the length of array or slice is larger than this,
-	// the buffer will be shorten as {...}.
-	BufferFoldThreshold = 1024
-	// PrintMapTypes when set to true will have map types will always appended to maps.
-	PrintMapTypes = true
-	// WithLineInfo add file name and line information to output
-	// call this function with care, because getting stack has performance penalty
-	WithLineInfo bool
-)
+// Default pretty printer. It's public so that you can configure it globally,
+// it is safe for concurrent use.
+var Default = newPrettyPrinter(3, DefaultOptions()) //nolint:mnd // pp.* => PrettyPrinter.* => formatAll
 
 // Internals
-var (
-	defaultOut          = os.Stdout
-	defaultWithLineInfo = false
+var defaultOut = os.Stdout
+
+// MethodMode defines whether Error, String and GoString methods of values
+// are used for printing them.
+type MethodMode int
+
+const (
+	// MethodsOff prints values by their structure only.
+	MethodsOff MethodMode = iota
+	// MethodsOn prints result of Error, String or GoString method, in that
+	// order of preference, instead of value structure.
+	MethodsOn
+	// MethodsVerbose prints both result of method and value structure.
+	MethodsVerbose
 )
 
-type PrettyPrinter struct {
-	// WithLineInfo adds file name and line information to output.
-	// Call this function with care, because getting stack has performance penalty.
-	WithLineInfo bool
-	// To support WithLineInfo, we need to know which frame we should look at.
-	// Thus callerLevel sets the number of frames it needs to skip.
-	callerLevel        int
-	out                io.Writer
-	currentScheme      ColorScheme
-	outLock            sync.Mutex
-	maxDepth           int
+// Options configures printing. Printers copy options when created, so
+// configuring printer does not affect printing in progress.
+type Options struct {
+	// Scheme is colours of printed values.
+	Scheme ColorScheme
+	// MaxDepth limits depth of printed values, -1 prints all levels.
+	MaxDepth           int
 	ColoringEnabled    bool
 	DecimalUint        bool
 	ThousandsSeparator bool
 	// This skips unexported fields of structs.
 	ExportedOnly bool
+	// PrintMapTypes when set to true will have map types will always appended to maps.
+	PrintMapTypes bool
+	// WithLineInfo adds file name and line information to output.
+	// Use it with care, because getting stack has performance penalty.
+	WithLineInfo bool
+	// If the length of array, slice or map is larger than this, only
+	// FoldContext elements from its head and tail are printed.
+	BufferFoldThreshold int
+	// FoldContext is number of elements kept on each side of folded collection.
+	FoldContext int
+	// If the length of string in bytes is larger than this, only
+	// StringFoldContext bytes from its head and tail are printed.
+	StringFoldThreshold int
+	// StringFoldContext is number of bytes kept on each side of folded string.
+	StringFoldContext int
+	// CompactWidth is maximum width of values printed on single line by
+	// SprintCompact and SprintLine.
+	CompactWidth int
+	// Methods is whether methods are used for printing values of types
+	// not configured with WithTypeMethods.
+	Methods MethodMode
+	// typeMethods is never modified, WithTypeMethods makes a copy.
+	typeMethods map[reflect.Type]MethodMode
+}
+
+// DefaultOptions returns options used by Default and New.
+func DefaultOptions() Options {
+	return Options{
+		Scheme:              defaultScheme,
+		MaxDepth:            -1,
+		ColoringEnabled:     true,
+		DecimalUint:         true,
+		ThousandsSeparator:  false,
+		ExportedOnly:        false,
+		PrintMapTypes:       true,
+		WithLineInfo:        false,
+		BufferFoldThreshold: 1024, //nolint:mnd
+		FoldContext:         8,    //nolint:mnd
+		StringFoldThreshold: 1024, //nolint:mnd
+		StringFoldContext:   32,   //nolint:mnd
+		CompactWidth:        80,   //nolint:mnd
+		Methods:             MethodsOff,
+		typeMethods:         nil,
+	}
+}
+
+// WithTypeMethods returns copy of options where methods are used for printing
+// values of typ according to mode, overriding Methods.
+func (o Options) WithTypeMethods(typ reflect.Type, mode MethodMode) Options {
+	o.typeMethods = maps.Clone(o.typeMethods)
+	if o.typeMethods == nil {
+		o.typeMethods = map[reflect.Type]MethodMode{}
+	}
+	o.typeMethods[typ] = mode
+	return o
+}
+
+type PrettyPrinter struct {
+	// To support WithLineInfo, we need to know which frame we should look at.
+	// Thus callerLevel sets the number of frames it needs to skip.
+	callerLevel int
+	out         io.Writer
+	outLock     sync.Mutex
+	opts        Options
+	optsLock    sync.RWMutex
 }
 
 // New creates a new PrettyPrinter that can be used to pretty print values
 func New() *PrettyPrinter {
-	return newPrettyPrinter(2) //nolint:mnd // PrettyPrinter.* => formatAll
+	return newPrettyPrinter(2, DefaultOptions()) //nolint:mnd // PrettyPrinter.* => formatAll
 }
 
-func newPrettyPrinter(callerLevel int) *PrettyPrinter {
+// NewWithOptions creates a new PrettyPrinter with given options.
+func NewWithOptions(opts Options) *PrettyPrinter {
+	return newPrettyPrinter(2, opts) //nolint:mnd // PrettyPrinter.* => formatAll
+}
+
+func newPrettyPrinter(callerLevel int, opts Options) *PrettyPrinter {
 	return &PrettyPrinter{
-		WithLineInfo:    defaultWithLineInfo,
-		callerLevel:     callerLevel,
-		out:             defaultOut,
-		currentScheme:   defaultScheme,
-		maxDepth:        -1,
-		ColoringEnabled: true,
-		DecimalUint:     true,
-		ExportedOnly:    false,
+		callerLevel: callerLevel,
+		out:         defaultOut,
+		opts:        opts,
 	}
 }
 
+// Options returns copy of pp's options.
+func (pp *PrettyPrinter) Options() Options {
+	pp.optsLock.RLock()
+	defer pp.optsLock.RUnlock()
+
+	return pp.opts
+}
+
+// Configure changes pp's options using configure, which is given a copy of
+// current options. Printing in progress is not affected.
+func (pp *PrettyPrinter) Configure(configure func(*Options)) {
+	pp.optsLock.Lock()
+	defer pp.optsLock.Unlock()
+
+	opts := pp.opts
+	configure(&opts)
+	pp.opts = opts
+}
+
 // Print prints given arguments.
 func (pp *PrettyPrinter) Print(a ...any) {
-	fmt.Fprint(pp.out, pp.formatAll(a)...)
+	fmt.Fprint(pp.GetOutput(), pp.formatAll(a)...)
 }
 
 // Printf prints a given format.
 func (pp *PrettyPrinter) Printf(format string, a ...any) {
-	fmt.Fprintf(pp.out, format, pp.formatAll(a)...)
+	fmt.Fprintf(pp.GetOutput(), format, pp.formatAll(a)...)
 }
 
 // Println prints given arguments with newline.
 func (pp *PrettyPrinter) Println(a ...any) {
-	fmt.Fprintln(pp.out, pp.formatAll(a)...)
+	fmt.Fprintln(pp.GetOutput(), pp.formatAll(a)...)
 }
 
 // Sprint formats given arguments and returns the result as string.
@@ -118,6 +201,27 @@ func (pp *PrettyPrinter) Sprint(a ...any) string {
 	return fmt.Sprint(pp.formatAll(a)...) //nolint:wrapcheck
 }
 
+// SprintCompact formats value on single line if it fits into CompactWidth,
+// on multiple lines otherwise.
+func (pp *PrettyPrinter) SprintCompact(value any) string {
+	return pp.formatCompact(value)
+}
+
+// SprintAround formats value on single line if it fits into width, on
+// multiple lines otherwise. If value is folded, focus element of collection
+// or byte of string is kept visible along with its neighbours. It is intended
+// for showing place of difference between values.
+func (pp *PrettyPrinter) SprintAround(value any, focus, width int) string {
+	opts := pp.Options()
+	return formatAround(value, &opts, focus, width)
+}
+
+// SprintLine formats value on single line. If it does not fit into
+// CompactWidth, deepest levels are elided as {...} until it does.
+func (pp *PrettyPrinter) SprintLine(value any) string {
+	return pp.formatLineFit(value)
+}
+
 // Sprintf formats with pretty print and returns the result as string.
 func (pp *PrettyPrinter) Sprintf(format string, a ...any) string {
 	return fmt.Sprintf(format, pp.formatAll(a)...) //nolint:wrapcheck
@@ -150,19 +254,19 @@ func (pp *PrettyPrinter) Errorf(format string, a ...any) error {
 
 // Fatal prints given arguments and finishes execution with exit status 1.
 func (pp *PrettyPrinter) Fatal(a ...any) {
-	fmt.Fprint(pp.out, pp.formatAll(a)...)
+	fmt.Fprint(pp.GetOutput(), pp.formatAll(a)...)
 	os.Exit(1)
 }
 
 // Fatalf prints a given format and finishes execution with exit status 1.
 func (pp *PrettyPrinter) Fatalf(format string, a ...any) {
-	fmt.Fprintf(pp.out, format, pp.formatAll(a)...)
+	fmt.Fprintf(pp.GetOutput(), format, pp.formatAll(a)...)
 	os.Exit(1)
 }
 
 // Fatalln prints given arguments with newline and finishes execution with exit status 1.
 func (pp *PrettyPrinter) Fatalln(a ...any) {
-	fmt.Fprintln(pp.out, pp.formatAll(a)...)
+	fmt.Fprintln(pp.GetOutput(), pp.formatAll(a)...)
 	os.Exit(1)
 }
 
@@ -176,6 +280,9 @@ func (pp *PrettyPrinter) SetOutput(o io.Writer) {
 
 // GetOutput returns pp's output.
 func (pp *PrettyPrinter) GetOutput() io.Writer {
+	pp.outLock.Lock()
+	defer pp.outLock.Unlock()
+
 	return pp.out
 }
 
@@ -196,39 +303,73 @@ func or(x, y string) string {
 
 // SetColorScheme takes a colorscheme used by all future Print calls.
 func (pp *PrettyPrinter) SetColorScheme(scheme ColorScheme) {
-	pp.currentScheme = ColorScheme{
-		Bool:            or(scheme.Bool, defaultScheme.Bool),
-		Integer:         or(scheme.Integer, defaultScheme.Integer),
-		Float:           or(scheme.Float, defaultScheme.Float),
-		String:          or(scheme.String, defaultScheme.String),
-		StringQuotation: or(scheme.StringQuotation, defaultScheme.StringQuotation),
-		EscapedChar:     or(scheme.EscapedChar, defaultScheme.EscapedChar),
-		FieldName:       or(scheme.FieldName, defaultScheme.FieldName),
-		PointerAdress:   or(scheme.PointerAdress, defaultScheme.PointerAdress),
-		Nil:             or(scheme.Nil, defaultScheme.Nil),
-		Time:            or(scheme.Time, defaultScheme.Time),
-		StructName:      or(scheme.StructName, defaultScheme.StructName),
-		ObjectLength:    or(scheme.ObjectLength, defaultScheme.ObjectLength),
-	}
+	pp.Configure(func(opts *Options) {
+		opts.Scheme = ColorScheme{
+			Bool:            or(scheme.Bool, defaultScheme.Bool),
+			Integer:         or(scheme.Integer, defaultScheme.Integer),
+			Float:           or(scheme.Float, defaultScheme.Float),
+			String:          or(scheme.String, defaultScheme.String),
+			StringQuotation: or(scheme.StringQuotation, defaultScheme.StringQuotation),
+			EscapedChar:     or(scheme.EscapedChar, defaultScheme.EscapedChar),
+			FieldName:       or(scheme.FieldName, defaultScheme.FieldName),
+			PointerAdress:   or(scheme.PointerAdress, defaultScheme.PointerAdress),
+			Nil:             or(scheme.Nil, defaultScheme.Nil),
+			Time:            or(scheme.Time, defaultScheme.Time),
+			StructName:      or(scheme.StructName, defaultScheme.StructName),
+			ObjectLength:    or(scheme.ObjectLength, defaultScheme.ObjectLength),
+		}
+	})
+}
+
+// SetTypeMethods sets whether methods are used for printing values of typ,
+// overriding Methods.
+func (pp *PrettyPrinter) SetTypeMethods(typ reflect.Type, mode MethodMode) {
+	pp.Configure(func(opts *Options) {
+		*opts = opts.WithTypeMethods(typ, mode)
+	})
 }
 
 // ResetColorScheme resets colorscheme to default.
 func (pp *PrettyPrinter) ResetColorScheme() {
-	pp.currentScheme = defaultScheme
+	pp.Configure(func(opts *Options) {
+		opts.Scheme = defaultScheme
+	})
 }
 
 func (pp *PrettyPrinter) formatAll(objects []any) []any {
+	opts := pp.Options()
 	results := make([]any, 0, len(objects)+1)
-	if pp.WithLineInfo || pp == Default && WithLineInfo { // fix for backwards capability
+	if opts.WithLineInfo {
 		_, fn, line, _ := runtime.Caller(pp.callerLevel)
 		results = append(results, fmt.Sprintf("%s:%d\n", fn, line))
 	}
 	for _, object := range objects {
-		results = append(results, pp.format(object))
+		results = append(results, newPrinter(object, &opts, false, -1).String())
 	}
 	return results
 }
 
+// SprintGo formats value as gofmt-formatted Go expression, so it can be
+// pasted into code. Pointers to non-composite values are printed as ptr(...)
+// calls, such helper is expected to be defined by user:
+//
+//	func ptr[T any](v T) *T { return &v }
+//
+// Types are qualified by package names and all struct fields are printed, so
+// expression is valid Go only outside of packages of its types and if it has
+// no unexported fields. Use SprintGoIn to get expression valid in given
+// package.
+func SprintGo(value any) string {
+	return formatGo("", value)
+}
+
+// SprintGoIn formats value as SprintGo does, but for use in package with
+// import path pkg: its types are not qualified, and unexported fields of
+// types of other packages, which cannot be set there, are omitted.
+func SprintGoIn(pkg string, value any) string {
+	return formatGo(pkg, value)
+}
+
 // Print prints given arguments.
 func Print(a ...any) {
 	Default.Print(a...)
@@ -249,6 +390,21 @@ func Sprint(a ...any) string {
 	return Default.Sprint(a...)
 }
 
+// SprintCompact formats value on single line if it fits, on multiple lines otherwise.
+func SprintCompact(value any) string {
+	return Default.SprintCompact(value)
+}
+
+// SprintAround formats value on single line if it fits into width, keeping focus element or byte visible if value is folded.
+func SprintAround(value any, focus, width int) string {
+	return Default.SprintAround(value, focus, width)
+}
+
+// SprintLine formats value on single line, eliding deepest levels if it does not fit.
+func SprintLine(value any) string {
+	return Default.SprintLine(value)
+}
+
 // Sprintf formats with pretty print and returns the result as string.
 func Sprintf(format string, a ...any) string {
 	return Default.Sprintf(format, a...)
@@ -319,6 +475,11 @@ func SetColorScheme(scheme ColorScheme) {
 	Default.SetColorScheme(scheme)
 }
 
+// SetTypeMethods sets whether methods are used for printing values of typ.
+func SetTypeMethods(typ reflect.Type, mode MethodMode) {
+	Default.SetTypeMethods(typ, mode)
+}
+
 // ResetColorScheme resets colorscheme to default.
 func ResetColorScheme() {
 	Default.ResetColorScheme()
@@ -326,5 +487,7 @@ func ResetColorScheme() {
 
 // SetMaxDepth sets the printer's Depth, -1 prints all
 func SetDefaultMaxDepth(v int) {
-	Default.maxDepth = v
+	Default.Configure(func(opts *Options) {
+		opts.MaxDepth = v
+	})
 }
@@ -2,8 +2,14 @@ package pp
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"unsafe"
 
 	"github.com/rprtr258/assert/internal/ass"
 )
@@ -23,7 +29,7 @@ func TestDefaultOutput(t *testing.T) {
 
 func TestColorScheme(t *testing.T) {
 	SetColorScheme(ColorScheme{})
-	ass.NotEqual(t, 0, len(Default.currentScheme.FieldName))
+	ass.NotEqual(t, 0, len(Default.Options().Scheme.FieldName))
 }
 
 func TestWithLineInfo(t *testing.T) {
@@ -33,20 +39,19 @@ func TestWithLineInfo(t *testing.T) {
 
 	outputWithLineInfo := &bytes.Buffer{}
 	SetDefaultOutput(outputWithLineInfo)
-	WithLineInfo = true
+	Default.Configure(func(opts *Options) { opts.WithLineInfo = true })
 	Print("abcde")
 
+	Default.Configure(func(opts *Options) { opts.WithLineInfo = false })
 	ResetDefaultOutput()
 
 	ass.NotEqual(t, outputWithLineInfo.Bytes(), outputWithoutLineInfo.Bytes())
 }
 
-func TestWithLineInfoBackwardsCompatible(t *testing.T) {
-	// Test that the global accessible field `WithLineInfo` does not mutate other instances
-
+func TestConfigureDoesNotAffectOtherInstances(t *testing.T) {
 	outputWithLineInfo := new(bytes.Buffer)
 	SetDefaultOutput(outputWithLineInfo)
-	WithLineInfo = true
+	Default.Configure(func(opts *Options) { opts.WithLineInfo = true })
 	Print("abcde")
 
 	outputWithoutLineInfo := new(bytes.Buffer)
@@ -56,6 +61,7 @@ func TestWithLineInfoBackwardsCompatible(t *testing.T) {
 
 	ass.NotEqual(t, outputWithLineInfo.Bytes(), outputWithoutLineInfo.Bytes())
 
+	Default.Configure(func(opts *Options) { opts.WithLineInfo = false })
 	ResetDefaultOutput()
 }
 
@@ -125,3 +131,186 @@ func TestStructPrintingWithTags(t *testing.T) {
 		})
 	}
 }
+
+type Enum int
+
+func (e Enum) String() string {
+	return [...]string{"Zero", "One"}[e]
+}
+
+type NilUnsafe struct{ s string }
+
+func (n *NilUnsafe) Error() string {
+	return n.s
+}
+
+type GoSyntax struct{}
+
+func (GoSyntax) GoString() string {
+	return "pp.GoSyntax{}"
+}
+
+func TestMethods(t *testing.T) {
+	pp := New()
+	pp.Configure(func(opts *Options) { opts.ColoringEnabled = false })
+
+	ass.Equal(t, "1", pp.Sprint(Enum(1)))
+
+	pp.Configure(func(opts *Options) { opts.Methods = MethodsOn })
+	ass.Equal(t, `pp.Enum("One")`, pp.Sprint(Enum(1)))
+	ass.Equal(t, `*pp.NilUnsafe("oops")`, pp.Sprint(&NilUnsafe{"oops"}))
+	// nil receiver panics, value itself is printed
+	ass.Equal(t, `(*pp.NilUnsafe)(nil)`, pp.Sprint((*NilUnsafe)(nil)))
+	ass.Equal(t, `pp.GoSyntax{}`, pp.Sprint(GoSyntax{}))
+	ass.Equal(t, "[]pp.Enum{\n    pp.Enum(\"Zero\"),\n    pp.Enum(\"One\"),\n}", pp.Sprint([]Enum{0, 1}))
+
+	pp.SetTypeMethods(reflect.TypeFor[Enum](), MethodsOff)
+	ass.Equal(t, "1", pp.Sprint(Enum(1)))
+
+	pp.SetTypeMethods(reflect.TypeFor[Enum](), MethodsVerbose)
+	ass.Equal(t, `pp.Enum("One") => 1`, pp.Sprint(Enum(1)))
+}
+
+type Decimal struct {
+	units int64
+	exp   int32
+}
+
+type Price struct {
+	Amount Decimal
+	cents  Decimal
+}
+
+// Celsius and Kelvin are used only by TestRegisterFormatter, so that their
+// formatters do not affect other tests.
+type (
+	Celsius int
+	Kelvin  int
+)
+
+func (c Celsius) String() string { return strconv.Itoa(int(c)) + "°C" }
+func (k Kelvin) String() string  { return strconv.Itoa(int(k)) + "K" }
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter(func(d Decimal) string {
+		return fmt.Sprintf("%de%d", d.units, d.exp)
+	})
+	RegisterFormatter(func(c Celsius) string {
+		return "formatted " + strconv.Itoa(int(c))
+	})
+	RegisterFormatter(func(Kelvin) string {
+		panic("formatter must be recovered")
+	})
+
+	pp := New()
+	pp.Configure(func(opts *Options) { opts.ColoringEnabled = false })
+
+	ass.Equal(t, "15e-1", pp.Sprint(Decimal{15, -1}))
+	ass.Equal(t, "pp.Price{\n    Amount: 15e-1,\n    cents:  3e0,\n}", pp.Sprint(Price{Decimal{15, -1}, Decimal{3, 0}}))
+	ass.Equal(t, "[]pp.Decimal{\n    1e0,\n}", pp.Sprint([]Decimal{{1, 0}}))
+	// formatter takes precedence over methods
+	pp.Configure(func(opts *Options) { opts.Methods = MethodsOn })
+	ass.Equal(t, "formatted 20", pp.Sprint(Celsius(20)))
+	// panicking formatter is skipped
+	ass.Equal(t, `pp.Kelvin("300K")`, pp.Sprint(Kelvin(300)))
+}
+
+type Pass struct {
+	hash string
+	salt []byte
+}
+
+type Account struct {
+	Login string
+	pass  Pass
+	Tags  map[string]int
+}
+
+func TestCompact(t *testing.T) {
+	pp := New()
+	pp.Configure(func(opts *Options) { opts.ColoringEnabled = false })
+	pp.Configure(func(opts *Options) { opts.CompactWidth = 120 })
+
+	user := Account{"a", Pass{"h", []byte("s")}, map[string]int{"x": 1, "y": 2}}
+	ass.Equal(t, `pp.Account{Login: "a", pass: pp.Pass{hash: "h", salt: []uint8{115}}, Tags: map[string]int{"x": 1, "y": 2}}`, pp.SprintLine(user))
+	ass.Equal(t, `pp.Account{Login: "a", pass: pp.Pass{hash: "h", salt: []uint8{115}}, Tags: map[string]int{"x": 1, "y": 2}}`, pp.SprintCompact(user))
+	ass.Equal(t, "[]int{1, 2, 3}", pp.SprintLine([]int{1, 2, 3}))
+	ass.Equal(t, `pp.Pass{hash: "", salt: []uint8(nil)}`, pp.SprintLine(Pass{}))
+
+	pp.Configure(func(opts *Options) { opts.CompactWidth = 70 })
+	ass.Equal(t, `pp.Account{Login: "a", pass: pp.Pass{...}, Tags: map[string]int{...}}`, pp.SprintLine(user))
+	ass.Equal(t, pp.Sprint(user), pp.SprintCompact(user))
+
+	// elided up to first level even if does not fit
+	pp.Configure(func(opts *Options) { opts.CompactWidth = 10 })
+	ass.Equal(t, `pp.Account{Login: "a", pass: pp.Pass{...}, Tags: map[string]int{...}}`, pp.SprintLine(user))
+}
+
+func TestFold(t *testing.T) {
+	pp := New()
+	pp.Configure(func(opts *Options) { opts.ColoringEnabled = false })
+
+	long := "abc" + strings.Repeat("-", 2000) + "xyz"
+	ass.Equal(t,
+		`"abc`+strings.Repeat("-", 29)+`…[1,942 bytes]…`+strings.Repeat("-", 29)+`xyz"`,
+		pp.Sprint(long))
+	// runes are not cut
+	ass.Equal(t,
+		`"`+strings.Repeat("й", 16)+`…[3,936 bytes]…`+strings.Repeat("й", 16)+`"`,
+		pp.Sprint(strings.Repeat("й", 2000)))
+
+	xs := make([]int, 10000)
+	for i := range xs {
+		xs[i] = i + 1
+	}
+	ass.Equal(t, "[]int{1, 2, 3, 4, 5, 6, 7, 8, … 9,984 more …, 9993, 9994, 9995, 9996, 9997, 9998, 9999, 10000}", pp.SprintLine(xs))
+	ass.Equal(t,
+		"[]int{1, 2, 3, 4, 5, 6, 7, 8, … 4,992 more …, 5001, 5002, 5003, 5004, 5005, 5006, 5007, 5008, 5009, 5010, 5011, 5012, 5013, 5014, 5015, 5016, … 4,976 more …, 9993, 9994, 9995, 9996, 9997, 9998, 9999, 10000}",
+		pp.SprintAround(xs, 5008, 1000))
+
+	focused := pp.SprintAround(strings.Repeat("a", 1000)+"b"+strings.Repeat("a", 1000), 1000, 1000)
+	ass.SContains(t, `…[936 bytes]…`+strings.Repeat("a", 32)+"b"+strings.Repeat("a", 31)+`…[937 bytes]…`, focused)
+}
+
+func TestConfigureConcurrent(t *testing.T) {
+	pp := New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 100 {
+			pp.Configure(func(opts *Options) { opts.ColoringEnabled = i%2 == 0 })
+			pp.SetTypeMethods(reflect.TypeFor[Enum](), MethodMode(i%3))
+		}
+	}()
+	for range 100 {
+		_ = pp.Sprint(map[Enum][]int{1: {1, 2}})
+	}
+	<-done
+
+	// options given to Configure are copy, typeMethods of previous options is not modified
+	opts := pp.Options()
+	pp.SetTypeMethods(reflect.TypeFor[Enum](), MethodsVerbose)
+	ass.Equal(t, MethodMode(99%3), opts.typeMethods[reflect.TypeFor[Enum]()])
+}
+
+func handler(int) string { return "" }
+
+func TestChanFunc(t *testing.T) {
+	pp := New()
+	pp.Configure(func(opts *Options) { opts.ColoringEnabled = false })
+
+	ch := make(chan int, 3)
+	ch <- 1
+	ass.True(t, strings.HasSuffix(pp.Sprint(ch), ", len: 1, cap: 3, open)"))
+	close(ch)
+	ass.True(t, strings.HasSuffix(pp.Sprint(ch), ", len: 1, cap: 3, closed)"))
+	ass.True(t, strings.HasPrefix(pp.Sprint((<-chan int)(ch)), "(<-chan int)(0x"))
+	ass.Equal(t, "(chan int)(nil)", pp.Sprint((chan int)(nil)))
+	ass.True(t, strings.HasSuffix(pp.Sprint(make(chan struct{})), ", len: 0, cap: 0, open)"))
+
+	fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	file, line := fn.FileLine(fn.Entry())
+	ass.Equal(t, "func(int) string {github.com/rprtr258/assert/internal/pp.handler at "+file+":"+strconv.Itoa(line)+"}", pp.Sprint(handler))
+	ass.Equal(t, "(func())(nil)", pp.Sprint((func())(nil)))
+	ass.Equal(t, "unsafe.Pointer(nil)", pp.Sprint(unsafe.Pointer(nil)))
+}
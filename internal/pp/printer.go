@@ -16,40 +16,81 @@ import (
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
+	"github.com/rprtr258/assert/internal/fun"
 	"github.com/rprtr258/assert/internal/scuf"
 )
 
 const indentWidth = 2
 
 func (pp *PrettyPrinter) format(object any) string {
-	return newPrinter(object, &pp.currentScheme, pp.maxDepth, pp.ColoringEnabled, pp.DecimalUint, pp.ExportedOnly, pp.ThousandsSeparator).String()
+	opts := pp.Options()
+	return newPrinter(object, &opts, false, -1).String()
 }
 
+// formatLine formats object on single line, levels deeper than compactDepth
+// are elided, -1 means no elision.
+func formatLine(object any, opts *Options, compactDepth int) string {
+	return newPrinter(object, opts, true, compactDepth).String()
+}
+
+// formatCompact formats object on single line if it fits into CompactWidth,
+// on multiple lines otherwise.
+func (pp *PrettyPrinter) formatCompact(object any) string {
+	opts := pp.Options()
+	return formatAround(object, &opts, 0, opts.CompactWidth)
+}
+
+// formatAround formats object on single line if it fits into width, on
+// multiple lines otherwise, keeping focus visible if object is folded.
+func formatAround(object any, opts *Options, focus, width int) string {
+	line := newPrinter(object, opts, true, -1)
+	line.focus = focus
+	if s := line.String(); scuf.Width(s) <= width {
+		return s
+	}
+
+	multiline := newPrinter(object, opts, false, -1)
+	multiline.focus = focus
+	return multiline.String()
+}
+
+// formatLineFit formats object on single line, eliding deep levels until it
+// fits into CompactWidth. Value elided up to first level is returned even if
+// it does not fit.
+func (pp *PrettyPrinter) formatLineFit(object any) string {
+	opts := pp.Options()
+	line := formatLine(object, &opts, -1)
+	for depth := maxCompactDepth; depth > 0 && scuf.Width(line) > opts.CompactWidth; depth-- {
+		line = formatLine(object, &opts, depth)
+	}
+	return line
+}
+
+// maxCompactDepth is depth from which elision is tried when value does not fit into line.
+const maxCompactDepth = 8
+
 func newPrinter(
 	object any,
-	currentScheme *ColorScheme,
-	maxDepth int,
-	coloringEnabled, decimalUint, exportedOnly, thousandsSeparator bool,
+	opts *Options,
+	compact bool,
+	compactDepth int,
 ) *printer {
 	buffer := &bytes.Buffer{}
 	tw := &tabwriter.Writer{}
 	tw.Init(buffer, indentWidth, 0, 1, ' ', 0)
 
 	printer := &printer{
-		Buffer:             buffer,
-		tw:                 tw,
-		depth:              0,
-		maxDepth:           maxDepth,
-		value:              reflect.ValueOf(object),
-		visited:            map[uintptr]bool{},
-		currentScheme:      currentScheme,
-		coloringEnabled:    coloringEnabled,
-		decimalUint:        decimalUint,
-		exportedOnly:       exportedOnly,
-		thousandsSeparator: thousandsSeparator,
-	}
-
-	if thousandsSeparator {
+		Buffer:       buffer,
+		tw:           tw,
+		depth:        0,
+		value:        reflect.ValueOf(object),
+		visited:      map[uintptr]bool{},
+		opts:         opts,
+		compact:      compact,
+		compactDepth: compactDepth,
+	}
+
+	if opts.ThousandsSeparator {
 		printer.localizedPrinter = message.NewPrinter(language.English)
 	}
 
@@ -58,29 +99,37 @@ func newPrinter(
 
 type printer struct {
 	*bytes.Buffer
-	tw                 *tabwriter.Writer
-	depth              int
-	maxDepth           int
-	value              reflect.Value
-	visited            map[uintptr]bool
-	currentScheme      *ColorScheme
-	coloringEnabled    bool
-	decimalUint        bool
-	exportedOnly       bool
-	thousandsSeparator bool
-	localizedPrinter   *message.Printer
+	tw               *tabwriter.Writer
+	depth            int
+	value            reflect.Value
+	visited          map[uintptr]bool
+	opts             *Options
+	localizedPrinter *message.Printer
+	// skipMethods disables methods for printed value, but not for nested ones
+	skipMethods bool
+	// compact prints value on single line
+	compact bool
+	// compactDepth is depth from which nested values are elided in compact mode, -1 means no elision
+	compactDepth int
+	// focus is index of element or byte kept visible if printed value is folded
+	focus int
 }
 
 func (p *printer) String() string {
+	if p.printFormatter() || p.printMethod() {
+		p.tw.Flush()
+		return p.Buffer.String()
+	}
+
 	switch p.value.Kind() {
 	case reflect.Bool:
-		p.colorPrint(p.raw(), p.currentScheme.Bool)
+		p.colorPrint(p.raw(), p.opts.Scheme.Bool)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Uintptr, reflect.Complex64, reflect.Complex128:
-		p.colorPrint(p.raw(), p.currentScheme.Integer)
+		p.colorPrint(p.raw(), p.opts.Scheme.Integer)
 	case reflect.Float32, reflect.Float64:
-		p.colorPrint(p.raw(), p.currentScheme.Float)
+		p.colorPrint(p.raw(), p.opts.Scheme.Float)
 	case reflect.String:
 		p.printString()
 	case reflect.Map:
@@ -92,15 +141,15 @@ func (p *printer) String() string {
 	case reflect.Slice:
 		p.printSlice()
 	case reflect.Chan:
-		p.printf("(%s)(%s)", p.colorizeType(p.value.Type()), p.pointerAddr())
+		p.printChan()
 	case reflect.Interface:
 		p.printInterface()
 	case reflect.Ptr:
 		p.printPtr()
 	case reflect.Func:
-		p.print(p.colorizeType(p.value.Type()) + " {...}")
+		p.printFunc()
 	case reflect.UnsafePointer:
-		p.print(p.colorizeType(p.value.Type()) + "(" + p.pointerAddr() + ")")
+		p.printUnsafePointer()
 	case reflect.Invalid:
 		p.print(p.nil())
 	default:
@@ -135,20 +184,114 @@ func (p *printer) colorPrint(text, mod string) {
 	p.print(p.colorize(text, mod))
 }
 
+// printFormatter prints value using registered formatter if there is one.
+func (p *printer) printFormatter() bool {
+	if !p.value.IsValid() || p.value.Kind() == reflect.Interface {
+		return false
+	}
+
+	format, ok := lookupFormatter(p.value.Type())
+	if !ok {
+		return false
+	}
+
+	text, ok := callFormatter(format, p.value)
+	if !ok {
+		return false
+	}
+
+	p.print(text)
+	return true
+}
+
+// callMethod returns result of Error, String or GoString method of v.
+// Panics are recovered, e.g. ones caused by nil pointer receivers.
+func callMethod(v any) (text string, isGoSyntax, ok bool) {
+	defer func() {
+		if recover() != nil {
+			text, isGoSyntax, ok = "", false, false
+		}
+	}()
+
+	switch v := v.(type) {
+	case error:
+		return v.Error(), false, true
+	case fmt.Stringer:
+		return v.String(), false, true
+	case fmt.GoStringer:
+		return v.GoString(), true, true
+	default:
+		return "", false, false
+	}
+}
+
+// printMethod prints value using its method if it is enabled and possible.
+func (p *printer) printMethod() bool {
+	if p.skipMethods ||
+		!p.value.IsValid() ||
+		p.value.Kind() == reflect.Interface || // underlying value is printed instead
+		!p.value.CanInterface() {
+		return false
+	}
+
+	mode, ok := p.opts.typeMethods[p.value.Type()]
+	if !ok {
+		mode = p.opts.Methods
+	}
+	if mode == MethodsOff {
+		return false
+	}
+
+	text, isGoSyntax, ok := callMethod(p.value.Interface())
+	if !ok {
+		return false
+	}
+
+	if isGoSyntax {
+		p.print(text)
+	} else {
+		p.printf(
+			"%s(%s)",
+			p.colorizeType(p.value.Type()),
+			p.colorize(strconv.Quote(text), p.opts.Scheme.String),
+		)
+	}
+
+	if mode == MethodsVerbose {
+		// skip methods for value itself only
+		p.print(" => " + newPrinterFrom(p, p.value, true).String())
+	}
+	return true
+}
+
 func (p *printer) printString() {
-	quoted := strconv.Quote(p.value.String())
+	s := p.value.String()
+	spans := foldStringSpans(s, p.opts.StringFoldThreshold, p.opts.StringFoldContext, p.focus)
+
+	p.colorPrint(`"`, p.opts.Scheme.StringQuotation)
+	for i, sp := range spans {
+		if i > 0 {
+			p.colorPrint("…["+formatCount(sp.lo-spans[i-1].hi)+" bytes]…", p.opts.Scheme.ObjectLength)
+		}
+		p.printQuoted(s[sp.lo:sp.hi])
+	}
+	p.colorPrint(`"`, p.opts.Scheme.StringQuotation)
+}
+
+// printQuoted prints s escaped as in Go string literal, without quotes.
+func (p *printer) printQuoted(s string) {
+	quoted := strconv.Quote(s)
 	quoted = quoted[1 : len(quoted)-1]
 
-	p.colorPrint(`"`, p.currentScheme.StringQuotation)
 	for quoted != "" {
 		pos := strings.IndexByte(quoted, '\\')
 		if pos == -1 {
-			p.colorPrint(quoted, p.currentScheme.String)
+			p.colorPrint(quoted, p.opts.Scheme.String)
 
 			break
 		}
 		if pos != 0 {
-			p.colorPrint(quoted[0:pos], p.currentScheme.String)
+			p.colorPrint(quoted[0:pos], p.opts.Scheme.String)
 		}
 
 		n := 1
@@ -162,10 +305,14 @@ func (p *printer) printString() {
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9': // "\000"
 			n = 3
 		}
-		p.colorPrint(quoted[pos:pos+n+1], p.currentScheme.EscapedChar)
+		p.colorPrint(quoted[pos:pos+n+1], p.opts.Scheme.EscapedChar)
 		quoted = quoted[pos+n+1:]
 	}
-	p.colorPrint(`"`, p.currentScheme.StringQuotation)
+}
+
+// foldedElems returns text printed instead of n folded elements.
+func (p *printer) foldedElems(n int) string {
+	return p.colorize("… "+formatCount(n)+" more …", p.opts.Scheme.ObjectLength)
 }
 
 func (p *printer) printMap() {
@@ -180,19 +327,51 @@ func (p *printer) printMap() {
 	}
 	p.visited[p.value.Pointer()] = true
 
-	if PrintMapTypes {
+	if p.compact {
+		if p.elided() {
+			p.print(p.colorizeType(p.value.Type()) + "{...}")
+			return
+		}
+
+		p.print(fun.Ternary(p.opts.PrintMapTypes, p.colorizeType(p.value.Type()), "") + "{")
+		p.indented(func() {
+			value := sortMap(p.value)
+			spans := foldSpans(value.Len(), p.opts.BufferFoldThreshold, p.opts.FoldContext, p.focus)
+			for j, sp := range spans {
+				if j > 0 {
+					p.print(", " + p.foldedElems(sp.lo-spans[j-1].hi))
+				}
+				for i := sp.lo; i < sp.hi; i++ {
+					if i > 0 {
+						p.print(", ")
+					}
+					p.print(p.format(value.keys[i]) + ": " + p.format(value.values[i]))
+				}
+			}
+		})
+		p.print("}")
+		return
+	}
+
+	if p.opts.PrintMapTypes {
 		p.print(p.colorizeType(p.value.Type()) + "{\n")
 	} else {
 		p.println("{")
 	}
 	p.indented(func() {
 		value := sortMap(p.value)
-		for i := range value.Len() {
-			p.indentPrintf(
-				"%s:\t%s,\n",
-				p.format(value.keys[i]),
-				p.format(value.values[i]),
-			)
+		spans := foldSpans(value.Len(), p.opts.BufferFoldThreshold, p.opts.FoldContext, p.focus)
+		for j, sp := range spans {
+			if j > 0 {
+				p.indentPrint(p.foldedElems(sp.lo-spans[j-1].hi) + "\n")
+			}
+			for i := sp.lo; i < sp.hi; i++ {
+				p.indentPrintf(
+					"%s:\t%s,\n",
+					p.format(value.keys[i]),
+					p.format(value.values[i]),
+				)
+			}
 		}
 	})
 	p.indentPrint("}")
@@ -208,20 +387,61 @@ func (p *printer) printStruct() {
 			return
 		case typ.String() == "big.Int":
 			bigInt := p.value.Interface().(big.Int)
-			p.print(p.colorize(bigInt.String(), p.currentScheme.Integer))
+			p.print(p.colorize(bigInt.String(), p.opts.Scheme.Integer))
 			return
 		case typ.String() == "big.Float":
 			bigFloat := p.value.Interface().(big.Float)
-			p.print(p.colorize(bigFloat.String(), p.currentScheme.Float))
+			p.print(p.colorize(bigFloat.String(), p.opts.Scheme.Float))
 			return
 		}
 	}
 
+	fields := p.structFields()
+	if len(fields) == 0 {
+		p.print(p.colorizeType(p.value.Type()) + "{}")
+		return
+	}
+
+	if p.compact {
+		if p.elided() {
+			p.print(p.colorizeType(p.value.Type()) + "{...}")
+			return
+		}
+
+		p.print(p.colorizeType(p.value.Type()) + "{")
+		p.indented(func() {
+			for j, i := range fields {
+				if j > 0 {
+					p.print(", ")
+				}
+				p.print(p.colorize(p.fieldName(i), p.opts.Scheme.FieldName) + ": " + p.format(p.value.Field(i)))
+			}
+		})
+		p.print("}")
+		return
+	}
+
+	p.println(p.colorizeType(p.value.Type()) + "{")
+	p.indented(func() {
+		for _, i := range fields {
+			p.indentPrintf(
+				"%s:\t%s,\n",
+				p.colorize(p.fieldName(i), p.opts.Scheme.FieldName),
+				p.format(p.value.Field(i)),
+			)
+		}
+	})
+	p.indentPrint("}")
+}
+
+// structFields returns indexes of struct fields to print.
+func (p *printer) structFields() []int {
+	typ := p.value.Type()
 	fields := make([]int, 0, p.value.NumField())
 	for i := range p.value.NumField() {
 		field := typ.Field(i)
 		// ignore unexported if needed
-		if p.exportedOnly && field.PkgPath != "" {
+		if p.opts.ExportedOnly && field.PkgPath != "" {
 			continue
 		}
 
@@ -237,45 +457,31 @@ func (p *printer) printStruct() {
 		}
 		fields = append(fields, i)
 	}
-	if len(fields) == 0 {
-		p.print(p.colorizeType(p.value.Type()) + "{}")
-		return
-	}
-
-	p.println(p.colorizeType(p.value.Type()) + "{")
-	p.indented(func() {
-		for _, i := range fields {
-			field := p.value.Type().Field(i)
-
-			fieldName := field.Name
-			if tag := field.Tag.Get("pp"); tag != "" {
-				tagName := strings.Split(tag, ",")
-				if tagName[0] != "" {
-					fieldName = tagName[0]
-				}
-			}
+	return fields
+}
 
-			p.indentPrintf(
-				"%s:\t%s,\n",
-				p.colorize(fieldName, p.currentScheme.FieldName),
-				p.format(p.value.Field(i)),
-			)
+// fieldName returns name of i-th struct field, possibly renamed by tag.
+func (p *printer) fieldName(i int) string {
+	field := p.value.Type().Field(i)
+	if tag := field.Tag.Get("pp"); tag != "" {
+		if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+			return tagName
 		}
-	})
-	p.indentPrint("}")
+	}
+	return field.Name
 }
 
 func (p *printer) printTime() {
 	tm := p.value.Interface().(time.Time)
 	p.printf(
 		"%s-%s-%s %s:%s:%s %s",
-		p.colorize(strconv.Itoa(tm.Year()), p.currentScheme.Time),
-		p.colorize(fmt.Sprintf("%02d", tm.Month()), p.currentScheme.Time),
-		p.colorize(fmt.Sprintf("%02d", tm.Day()), p.currentScheme.Time),
-		p.colorize(fmt.Sprintf("%02d", tm.Hour()), p.currentScheme.Time),
-		p.colorize(fmt.Sprintf("%02d", tm.Minute()), p.currentScheme.Time),
-		p.colorize(fmt.Sprintf("%02d", tm.Second()), p.currentScheme.Time),
-		p.colorize(tm.Location().String(), p.currentScheme.Time),
+		p.colorize(strconv.Itoa(tm.Year()), p.opts.Scheme.Time),
+		p.colorize(fmt.Sprintf("%02d", tm.Month()), p.opts.Scheme.Time),
+		p.colorize(fmt.Sprintf("%02d", tm.Day()), p.opts.Scheme.Time),
+		p.colorize(fmt.Sprintf("%02d", tm.Hour()), p.opts.Scheme.Time),
+		p.colorize(fmt.Sprintf("%02d", tm.Minute()), p.opts.Scheme.Time),
+		p.colorize(fmt.Sprintf("%02d", tm.Second()), p.opts.Scheme.Time),
+		p.colorize(tm.Location().String(), p.opts.Scheme.Time),
 	)
 }
 
@@ -303,15 +509,36 @@ func (p *printer) printArray() {
 		p.visited[p.value.Pointer()] = true
 	}
 
-	// Fold a large buffer
-	if p.value.Len() > BufferFoldThreshold {
+	if p.compact && p.elided() {
 		p.print(p.colorizeType(p.value.Type()) + "{...}")
 		return
 	}
 
+	// Fold a large buffer
+	spans := foldSpans(p.value.Len(), p.opts.BufferFoldThreshold, p.opts.FoldContext, p.focus)
+
+	if p.compact {
+		p.print(p.colorizeType(p.value.Type()) + "{")
+		p.indented(func() {
+			for j, sp := range spans {
+				if j > 0 {
+					p.print(", " + p.foldedElems(sp.lo-spans[j-1].hi))
+				}
+				for i := sp.lo; i < sp.hi; i++ {
+					if i > 0 {
+						p.print(", ")
+					}
+					p.print(p.format(p.value.Index(i)))
+				}
+			}
+		})
+		p.print("}")
+		return
+	}
+
 	p.println(p.colorizeType(p.value.Type()) + "{")
 	p.indented(func() {
-		var groupsize int
+		groupsize := 1
 		switch p.value.Type().Elem().Kind() {
 		case reflect.Uint8:
 			groupsize = 16
@@ -321,22 +548,20 @@ func (p *printer) printArray() {
 			groupsize = 4
 		}
 
-		if groupsize > 0 {
-			// TODO: iter by batches
-			for i := 0; i < p.value.Len(); i += groupsize {
+		for j, sp := range spans {
+			if j > 0 {
+				p.indentPrint(p.foldedElems(sp.lo-spans[j-1].hi) + "\n")
+			}
+			for i := sp.lo; i < sp.hi; i += groupsize {
 				p.print(p.indent())
-				for j := 0; j < groupsize && i+j < p.value.Len(); j++ {
-					p.print(p.format(p.value.Index(i+j)) + ",")
-					if j+1 < groupsize && i+j+1 < p.value.Len() {
+				for k := i; k < min(i+groupsize, sp.hi); k++ {
+					if k > i {
 						p.print(" ")
 					}
+					p.print(p.format(p.value.Index(k)) + ",")
 				}
 				p.print("\n")
 			}
-		} else {
-			for i := range p.value.Len() {
-				p.indentPrint(p.format(p.value.Index(i)) + ",\n")
-			}
 		}
 	})
 	p.indentPrint("}")
@@ -370,7 +595,7 @@ func (p *printer) printPtr() {
 }
 
 func (p *printer) pointerAddr() string {
-	return p.colorize(fmt.Sprintf("%#v", p.value.Pointer()), p.currentScheme.PointerAdress)
+	return p.colorize(fmt.Sprintf("%#v", p.value.Pointer()), p.opts.Scheme.PointerAdress)
 }
 
 var (
@@ -390,22 +615,27 @@ func (p *printer) colorizeType(typ reflect.Type) string {
 
 	if _reTypeArray.MatchString(typeStr) {
 		num := regexp.MustCompile(`\d+`).FindString(typeStr)
-		prefix = fmt.Sprintf("[%s]", p.colorize(num, p.currentScheme.ObjectLength))
+		prefix = fmt.Sprintf("[%s]", p.colorize(num, p.opts.Scheme.ObjectLength))
 		typeStr = typeStr[2+len(num):]
 	}
 
 	if _reTypeStruct.MatchString(typeStr) {
 		ts := strings.Split(typeStr, ".")
-		typeStr = ts[0] + "." + p.colorize(ts[1], p.currentScheme.StructName)
+		typeStr = ts[0] + "." + p.colorize(ts[1], p.opts.Scheme.StructName)
 	} else {
-		typeStr = p.colorize(typeStr, p.currentScheme.StructName)
+		typeStr = p.colorize(typeStr, p.opts.Scheme.StructName)
 	}
 	return prefix + typeStr
 }
 
+// elided reports whether nested values are elided at current depth in compact mode.
+func (p *printer) elided() bool {
+	return p.compactDepth != -1 && p.depth >= p.compactDepth
+}
+
 func (p *printer) indented(proc func()) {
 	p.depth++
-	if p.maxDepth == -1 || p.depth <= p.maxDepth {
+	if p.opts.MaxDepth == -1 || p.depth <= p.opts.MaxDepth {
 		proc()
 	}
 	p.depth--
@@ -427,31 +657,31 @@ func (p *printer) raw() string {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return p.fmtOrLocalizedSprintf("%v", p.value.Int())
 	case reflect.Uint, reflect.Uintptr:
-		if p.decimalUint {
+		if p.opts.DecimalUint {
 			return p.fmtOrLocalizedSprintf("%d", p.value.Uint())
 		} else {
 			return fmt.Sprintf("%#v", p.value.Uint())
 		}
 	case reflect.Uint8:
-		if p.decimalUint {
+		if p.opts.DecimalUint {
 			return strconv.FormatUint(p.value.Uint(), 10)
 		} else {
 			return fmt.Sprintf("0x%02x", p.value.Uint())
 		}
 	case reflect.Uint16:
-		if p.decimalUint {
+		if p.opts.DecimalUint {
 			return p.fmtOrLocalizedSprintf("%d", p.value.Uint())
 		} else {
 			return fmt.Sprintf("0x%04x", p.value.Uint())
 		}
 	case reflect.Uint32:
-		if p.decimalUint {
+		if p.opts.DecimalUint {
 			return p.fmtOrLocalizedSprintf("%d", p.value.Uint())
 		} else {
 			return fmt.Sprintf("0x%08x", p.value.Uint())
 		}
 	case reflect.Uint64:
-		if p.decimalUint {
+		if p.opts.DecimalUint {
 			return p.fmtOrLocalizedSprintf("%d", p.value.Uint())
 		} else {
 			return fmt.Sprintf("0x%016x", p.value.Uint())
@@ -466,32 +696,30 @@ func (p *printer) raw() string {
 }
 
 func (p *printer) nil() string {
-	return p.colorize("nil", p.currentScheme.Nil)
+	return p.colorize("nil", p.opts.Scheme.Nil)
 }
 
 func (p *printer) colorize(text string, mod scuf.Mod) string {
-	if !p.coloringEnabled {
+	if !p.opts.ColoringEnabled {
 		return text
 	}
 	return scuf.String(text, mod)
 }
 
 func (p *printer) format(object any) string {
-	pp := newPrinter(
-		object,
-		p.currentScheme,
-		p.maxDepth,
-		p.coloringEnabled,
-		p.decimalUint,
-		p.exportedOnly,
-		p.thousandsSeparator,
-	)
+	return newPrinterFrom(p, object, false).String()
+}
+
+// newPrinterFrom creates printer for nested object with same settings as p.
+func newPrinterFrom(p *printer, object any, skipMethods bool) *printer {
+	pp := newPrinter(object, p.opts, p.compact, p.compactDepth)
 	pp.depth = p.depth
 	pp.visited = p.visited
+	pp.skipMethods = skipMethods
 	if value, ok := object.(reflect.Value); ok {
 		pp.value = value
 	}
-	return pp.String()
+	return pp
 }
 
 func (p *printer) indent() string {
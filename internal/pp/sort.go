@@ -2,6 +2,8 @@
 package pp
 
 import (
+	"cmp"
+	"fmt"
 	"reflect"
 	"sort"
 )
@@ -22,34 +24,86 @@ func (s *sortedMap) Swap(i, j int) {
 }
 
 func (s *sortedMap) Less(i, j int) bool {
-	a, b := s.keys[i], s.keys[j]
-	if a.Type() != b.Type() {
-		return false // give up
+	if c := compare(s.keys[i], s.keys[j]); c != 0 {
+		return c < 0
 	}
+	// keys are equal only if they are or contain NaN, so entries are ordered
+	// by printed values then, to be printed in the same order every time
+	return fmt.Sprint(s.values[i]) < fmt.Sprint(s.values[j])
+}
 
-	// Return true if b is bigger
+// compare compares values of the same type, returning -1, 0 or 1. Order is
+// total over comparable values and follows fmt's map printing order:
+//   - numbers and strings are ordered naturally, NaN is less than other floats
+//     and equal to NaN
+//   - false is less than true
+//   - complex numbers are ordered by real, then by imaginary part
+//   - pointers and channels are ordered by address
+//   - structs and arrays are ordered by fields and elements in order
+//   - interfaces are ordered by dynamic type name first, nil is the least
+func compare(a, b reflect.Value) int {
 	switch a.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return a.Int() < b.Int()
+		return cmp.Compare(a.Int(), b.Int())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return a.Uint() < b.Uint()
+		return cmp.Compare(a.Uint(), b.Uint())
 	case reflect.String:
-		return a.String() < b.String()
+		return cmp.Compare(a.String(), b.String())
 	case reflect.Float32, reflect.Float64:
-		if a.Float() != a.Float() || b.Float() != b.Float() {
-			return false // NaN
-		}
-		return a.Float() < b.Float()
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		ac, bc := a.Complex(), b.Complex()
+		return cmp.Or(
+			cmp.Compare(real(ac), real(bc)),
+			cmp.Compare(imag(ac), imag(bc)),
+		)
 	case reflect.Bool:
-		return !a.Bool() && b.Bool()
-	case reflect.Ptr:
-		return a.Pointer() < b.Pointer()
+		switch {
+		case a.Bool() == b.Bool():
+			return 0
+		case b.Bool():
+			return -1
+		default:
+			return 1
+		}
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Chan:
+		return cmp.Compare(a.Pointer(), b.Pointer())
 	case reflect.Struct:
-		return a.NumField() < b.NumField()
+		for i := range a.NumField() {
+			if c := compare(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
 	case reflect.Array:
-		return a.Len() < b.Len()
+		for i := range a.Len() {
+			if c := compare(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Interface:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+
+		ae, be := a.Elem(), b.Elem()
+		if c := cmp.Compare(ae.Type().String(), be.Type().String()); c != 0 {
+			return c
+		}
+		if ae.Type() != be.Type() {
+			// distinct types with the same name, e.g. declared in different scopes
+			return cmp.Compare(reflect.ValueOf(ae.Type()).Pointer(), reflect.ValueOf(be.Type()).Pointer())
+		}
+		return compare(ae, be)
 	default:
-		return false // not supported yet
+		// not comparable, cannot be map key
+		return 0
 	}
 }
 
@@ -58,11 +112,12 @@ func sortMap(value reflect.Value) *sortedMap {
 		panic("sortMap is used for a non-Map value")
 	}
 
-	keys := value.MapKeys()
-
-	values := make([]reflect.Value, value.Len())
-	for i, key := range keys {
-		values[i] = value.MapIndex(key)
+	// not MapIndex, since NaN keys are not found by it
+	keys := make([]reflect.Value, 0, value.Len())
+	values := make([]reflect.Value, 0, value.Len())
+	for iter := value.MapRange(); iter.Next(); {
+		keys = append(keys, iter.Key())
+		values = append(values, iter.Value())
 	}
 
 	sorted := &sortedMap{
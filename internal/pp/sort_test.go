@@ -0,0 +1,80 @@
+package pp
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rprtr258/assert/internal/ass"
+)
+
+type sortKey struct {
+	a int
+	b string
+}
+
+func sortedKeys[K comparable, V any](m map[K]V) []K {
+	sorted := sortMap(reflect.ValueOf(m))
+	keys := make([]K, 0, sorted.Len())
+	for _, k := range sorted.keys {
+		key, _ := k.Interface().(K) // nil for nil interface keys
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestSortMap(t *testing.T) {
+	ass.Equal(t,
+		[]sortKey{{1, "a"}, {1, "b"}, {2, "a"}},
+		sortedKeys(map[sortKey]int{{2, "a"}: 0, {1, "b"}: 0, {1, "a"}: 0}))
+	ass.Equal(t,
+		[][2]int{{0, 5}, {1, 0}, {1, 2}},
+		sortedKeys(map[[2]int]int{{1, 2}: 0, {0, 5}: 0, {1, 0}: 0}))
+	ass.Equal(t,
+		[]complex128{complex(1, -1), complex(1, 2), complex(2, 0)},
+		sortedKeys(map[complex128]int{complex(2, 0): 0, complex(1, 2): 0, complex(1, -1): 0}))
+	ass.Equal(t,
+		[]bool{false, true},
+		sortedKeys(map[bool]int{true: 0, false: 0}))
+
+	// nil first, then by type name: int < string, then by value
+	ass.Equal(t,
+		[]any{nil, 1, 2, "a", "b"},
+		sortedKeys(map[any]int{"b": 0, 2: 0, nil: 0, "a": 0, 1: 0}))
+
+	// NaN is least, its keys are never equal so all are present
+	floats := sortedKeys(map[float64]int{1: 0, math.NaN(): 0, math.Inf(-1): 0, math.NaN(): 0})
+	ass.Equal(t, 4, len(floats))
+	ass.True(t, math.IsNaN(floats[0]) && math.IsNaN(floats[1]))
+	ass.Equal(t, []float64{math.Inf(-1), 1}, floats[2:])
+}
+
+func TestSortMapDeterministic(t *testing.T) {
+	m := map[any]string{}
+	for i := range 20 {
+		m[sortKey{i % 3, string(rune('a' + i))}] = ""
+		m[i] = ""
+	}
+
+	pp := New()
+	expected := pp.Sprint(m)
+	for range 10 {
+		ass.Equal(t, expected, pp.Sprint(m))
+	}
+}
+
+func TestSortMapNaN(t *testing.T) {
+	m := map[float64]int{1: 0}
+	for i := range 5 {
+		m[math.NaN()] = 5 - i
+	}
+
+	pp := New()
+	pp.Configure(func(opts *Options) { opts.ColoringEnabled = false })
+	expected := pp.Sprint(m)
+	ass.True(t, strings.Index(expected, " 1,\n") < strings.Index(expected, " 5,\n"))
+	for range 10 {
+		ass.Equal(t, expected, pp.Sprint(m))
+	}
+}
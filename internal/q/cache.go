@@ -0,0 +1,93 @@
+package q
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sync"
+	"time"
+)
+
+// parsedFile is parsed source file with its calls indexed by line.
+type parsedFile struct {
+	file *ast.File
+	// calls are call expressions by line of their opening paren, in source order
+	calls map[int][]*ast.CallExpr
+	// params are parameter names of top-level functions by function name
+	params map[string][]string
+}
+
+func parseFile(filename string) (*parsedFile, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, nil, 0)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	res := &parsedFile{
+		file:   f,
+		calls:  map[int][]*ast.CallExpr{},
+		params: map[string][]string{},
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			line := fset.Position(call.Lparen).Line
+			res.calls[line] = append(res.calls[line], call)
+		}
+		return true
+	})
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+
+		names := []string{}
+		for _, field := range fn.Type.Params.List {
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+		}
+		res.params[fn.Name.Name] = names
+	}
+	return res, nil
+}
+
+// cacheEntry is a file parsed once, when it has given modification time and size.
+type cacheEntry struct {
+	modTime time.Time
+	size    int64
+	once    sync.Once
+	file    *parsedFile
+	err     error
+}
+
+// _cache maps file paths to *cacheEntry.
+var _cache sync.Map
+
+// loadFile returns parsed file, parsing it only if it was not parsed before
+// or was changed since. Concurrent loads of the same file parse it once.
+func loadFile(filename string) (*parsedFile, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err //nolint:wrapcheck
+	}
+
+	fresh := &cacheEntry{modTime: info.ModTime(), size: info.Size()}
+	v, loaded := _cache.LoadOrStore(filename, fresh)
+	entry := v.(*cacheEntry) //nolint:forcetypeassert // only entries are stored
+	if loaded && (!entry.modTime.Equal(fresh.modTime) || entry.size != fresh.size) {
+		if _cache.CompareAndSwap(filename, entry, fresh) {
+			entry = fresh
+		} else if v, ok := _cache.Load(filename); ok {
+			// replaced concurrently
+			entry = v.(*cacheEntry) //nolint:forcetypeassert // only entries are stored
+		}
+	}
+
+	entry.once.Do(func() {
+		entry.file, entry.err = parseFile(filename)
+	})
+	return entry.file, entry.err
+}
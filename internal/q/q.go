@@ -2,11 +2,15 @@ package q
 
 import (
 	"go/ast"
-	"go/parser"
 	"go/printer"
 	"go/token"
+	"path"
+	"reflect"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // exprToString returns the source text underlying the given ast.Expr.
@@ -41,76 +45,208 @@ func argName(arg ast.Expr) string {
 	}
 }
 
-// isPackage returns true if the given function call expression is in the packageName package.
-func isPackage(n *ast.CallExpr, packageName string) bool {
-	sel, ok := n.Fun.(*ast.SelectorExpr) // SelectorExpr example: a.B()
-	if !ok {
-		return false
-	}
+// callMatcher matches calls of functions of a package in a file, taking into
+// account how package is imported there.
+type callMatcher struct {
+	// pkgNames are names package is imported as
+	pkgNames []string
+	// bare is whether functions are called without package name, that is
+	// package is dot-imported or file is in the package itself
+	bare bool
+}
 
-	ident, ok := sel.X.(*ast.Ident) // sel.X is the part that precedes the .
-	if !ok {
-		return false
+// newCallMatcher returns matcher of calls of functions from package with
+// import path pkgPath in file f. Package name is assumed to be last element
+// of import path.
+func newCallMatcher(f *ast.File, pkgPath string) callMatcher {
+	pkgName := path.Base(pkgPath)
+	m := callMatcher{
+		pkgNames: nil,
+		bare:     f.Name.Name == pkgName,
 	}
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || importPath != pkgPath {
+			continue
+		}
 
-	return ident.Name == packageName
+		switch {
+		case spec.Name == nil:
+			m.pkgNames = append(m.pkgNames, pkgName)
+		case spec.Name.Name == ".":
+			m.bare = true
+		case spec.Name.Name != "_":
+			m.pkgNames = append(m.pkgNames, spec.Name.Name)
+		}
+	}
+	return m
 }
 
-// isBareFunction returns true if the given function call expression is <funcName>().
-func isBareFunction(n *ast.CallExpr, funcName string) bool {
-	ident, ok := n.Fun.(*ast.Ident)
-	return ok && ident.Name == funcName
+// called returns name of function of matched package called by the given
+// function call expression, possibly with explicit type arguments.
+func (m callMatcher) called(n *ast.CallExpr) (string, bool) {
+	fun := n.Fun
+	switch f := fun.(type) {
+	case *ast.IndexExpr: // Equal[int](...)
+		fun = f.X
+	case *ast.IndexListExpr: // Equal[int, string](...)
+		fun = f.X
+	}
+
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name, m.bare
+	case *ast.SelectorExpr: // a.B(...)
+		ident, ok := f.X.(*ast.Ident)
+		if !ok || f.Sel == nil {
+			return "", false
+		}
+		return f.Sel.Name, slices.Contains(m.pkgNames, ident.Name)
+	default:
+		return "", false
+	}
 }
 
-// isFuncCall returns true if the given function call expression is
-// <funcName>() or <pkgName>.<funcName>().
-func isFuncCall(n *ast.CallExpr, pkgName, funcName string) bool {
-	return isBareFunction(n, funcName) || isPackage(n, pkgName)
+// match returns true if the given function call expression is call of
+// function funcName of matched package.
+func (m callMatcher) match(n *ast.CallExpr, funcName string) bool {
+	name, ok := m.called(n)
+	return ok && name == funcName
 }
 
-// argNames finds the q.Q() call at the given filename/line number and
-// returns its arguments as a slice of strings. If the argument is a literal,
-// argNames will return an empty string at the index position of that argument.
-// For example, q.Q(ip, port, 5432) would return []string{"ip", "port", ""}.
-// argNames returns an error if the source text cannot be parsed.
-func argNames(filename string, line int, pkgName, funcName string) ([]string, bool) {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, filename, nil, 0)
+// argNames finds the call of funcName from package with import path pkgPath
+// at the given filename/line number and returns its arguments as a slice of strings. If the argument is
+// a literal, argNames will return an empty string at the index position of
+// that argument. For example, q.Q(ip, port, 5432) would return
+// []string{"ip", "port", ""}.
+//
+// Line reported by runtime for a call is the line of its opening paren, so
+// multi-line calls and calls nested into arguments of other calls are
+// matched by it. Runtime provides no column information, so if several
+// matching calls open on the same line, it is ambiguous which one is called
+// and no names are returned.
+// argNames returns false if the source text cannot be parsed.
+func argNames(filename string, line int, pkgPath, funcName string) ([]string, bool) {
+	f, err := loadFile(filename)
 	if err != nil {
 		return nil, false
 	}
 
-	var names []string
-	ast.Inspect(f, func(n ast.Node) bool {
-		switch call := n.(type) {
-		case *ast.CallExpr:
-			if fset.Position(call.Pos()).Line == line && isFuncCall(call, pkgName, funcName) {
-				for _, arg := range call.Args {
-					names = append(names, argName(arg))
-				}
-			}
+	matcher := newCallMatcher(f.file, pkgPath)
+	var call *ast.CallExpr
+	for _, c := range f.calls[line] {
+		if !matcher.match(c, funcName) {
+			continue
+		}
+		if call != nil {
+			return nil, true
 		}
+		call = c
+	}
+	if call == nil {
+		return nil, true
+	}
 
-		return true
-	})
-	return names, true
+	return callArgNames(call), true
 }
 
-// assert.* -> Q >> runtime.Caller
+// callArgNames returns names of arguments of call as argName does.
+func callArgNames(call *ast.CallExpr) []string {
+	names := make([]string, 0, len(call.Args))
+	for _, arg := range call.Args {
+		names = append(names, argName(arg))
+	}
+	return names
+}
+
+// assert.* -> Q >> caller
 const CallDepth = 2
 
-// TODO: check not pkgName, but full package name, as it might be aliased
-func Q(pkgName, funcName string) []string {
-	_, file, line, ok := runtime.Caller(CallDepth)
-	if !ok {
+// _helpers is set of full names of registered helper functions.
+var _helpers sync.Map
+
+// RegisterHelper marks function fn as assertion helper: argument names of
+// assertions called inside of it are resolved further up to its call site,
+// if they are names of its parameters. fn must be a plain function, not a
+// method or closure.
+func RegisterHelper(fn any) {
+	if f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()); f != nil {
+		_helpers.Store(trimTypeArgs(f.Name()), struct{}{})
+	}
+}
+
+// trimTypeArgs removes type arguments placeholder from generic function name.
+func trimTypeArgs(name string) string {
+	return strings.TrimSuffix(name, "[...]")
+}
+
+// splitFuncName splits full function name, e.g. github.com/a/b.F, into
+// package import path and function name.
+func splitFuncName(name string) (pkgPath, funcName string) {
+	slash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[slash+1:], '.')
+	if dot == -1 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+1+dot+1:]
+}
+
+// paramNames returns names of parameters of top-level function funcName declared in file.
+func paramNames(filename, funcName string) []string {
+	f, err := loadFile(filename)
+	if err != nil {
+		return nil
+	}
+
+	return f.params[funcName]
+}
+
+// callers returns frames starting from function calling function which is
+// skip frames up the stack, inlined frames are taken into account.
+func callers(skip int) []runtime.Frame {
+	pcs := make([]uintptr, 32) //nolint:mnd // deep enough for helpers chains
+	// runtime.Callers, callers
+	n := runtime.Callers(skip+2, pcs) //nolint:mnd
+
+	var res []runtime.Frame
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		res = append(res, frame)
+		if !more {
+			return res
+		}
+	}
+}
+
+func Q(pkgPath, funcName string) []string {
+	frames := callers(CallDepth)
+	if len(frames) == 0 || frames[0].File == "" {
 		return nil
 	}
 
 	// <pkgName>.<funcName>(foo, bar, baz) -> []string{"foo", "bar", "baz"}
-	names, ok := argNames(file, line, pkgName, funcName)
+	names, ok := lookupArgNames(frames[0].File, frames[0].Line, pkgPath, funcName)
 	if !ok {
 		return nil
 	}
 
+	// replace helper parameters with helper arguments names
+	for i := 0; i+1 < len(frames) && names != nil; i++ {
+		helper := trimTypeArgs(frames[i].Function)
+		if _, ok := _helpers.Load(helper); !ok {
+			break
+		}
+
+		helperPkgPath, helperName := splitFuncName(helper)
+		params := paramNames(frames[i].File, helperName)
+		outer, _ := lookupArgNames(frames[i+1].File, frames[i+1].Line, helperPkgPath, helperName)
+		for j, name := range names {
+			if k := slices.Index(params, name); k != -1 && k < len(outer) {
+				names[j] = outer[k]
+			}
+		}
+	}
+
 	return names
 }
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rprtr258/assert/internal/ass"
 )
@@ -360,6 +364,151 @@ func TestArgNames(t *testing.T) {
 	}, got)
 }
 
+func TestArgNamesCallLayouts(t *testing.T) {
+	const filename = "./testdata/calls.go"
+	for name, test := range map[string]struct {
+		line int
+		want []string
+	}{
+		"selector split":       {15, []string{"t", "a", "b"}},
+		"selector line":        {14, nil},
+		"same line ambiguous":  {17, nil},
+		"multi-line":           {19, []string{"t", "a", "b"}},
+		"multi-line arguments": {20, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, ok := argNames(filename, test.line, "github.com/rprtr258/assert", "Equal")
+			ass.True(t, ok)
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestArgNamesImports(t *testing.T) {
+	const filename = "./testdata/imports.go"
+	for name, test := range map[string]struct {
+		line int
+		want []string
+	}{
+		"alias":            {16, []string{"t", "x", "y"}},
+		"dot import":       {17, []string{"t", "x", "y"}},
+		"other package":    {18, nil},
+		"type instantiate": {19, []string{"t", "x", "y"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, ok := argNames(filename, test.line, "github.com/rprtr258/assert", "Equal")
+			ass.True(t, ok)
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
+
+// _captured is names of arguments of capture calls, in order of calls
+var _captured [][]string
+
+func capture(...any) []string {
+	names := Q("github.com/rprtr258/assert/internal/q", "capture")
+	_captured = append(_captured, names)
+	return names
+}
+
+func TestQ(t *testing.T) {
+	a, b := 1, 2
+	ass.Equal(t, []string{"a", "b"}, capture(a, b))
+	ass.Equal(t, []string{"a", "b"}, capture(
+		a,
+		b,
+	))
+	// same line calls are ambiguous
+	ass.Equal(t, [][]string{nil, nil}, [][]string{capture(a), capture(b)})
+
+	// nested call on separate line
+	_captured = nil
+	capture(a,
+		capture(b))
+	ass.Equal(t, [][]string{{"b"}, {"a", "capture(b)"}}, _captured)
+}
+
+func captureHelper(x any, ys ...any) []string {
+	return capture(ys, x, 1)
+}
+
+func captureNestedHelper(z any) []string {
+	return captureHelper(z, z)
+}
+
+func TestQHelpers(t *testing.T) {
+	a, b := 1, 2
+	RegisterHelper(captureHelper)
+	ass.Equal(t, []string{"b", "a", "1"}, captureHelper(a, b))
+	// names propagate through chain of helpers
+	RegisterHelper(captureNestedHelper)
+	ass.Equal(t, []string{"a", "a", "1"}, captureNestedHelper(a))
+}
+
+func TestLoadFileCache(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "main.go")
+	ass.NoError(t, os.WriteFile(filename, []byte("package main\n\nfunc f(a int) {}\n"), 0o600))
+
+	// concurrent loads share single parse
+	files := make([]*parsedFile, 8)
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Go(func() {
+			f, err := loadFile(filename)
+			ass.NoError(t, err)
+			files[i] = f
+		})
+	}
+	wg.Wait()
+	for _, f := range files[1:] {
+		ass.True(t, f == files[0])
+	}
+	ass.Equal(t, []string{"a"}, paramNames(filename, "f"))
+
+	// changed file is parsed again
+	ass.NoError(t, os.WriteFile(filename, []byte("package main\n\nfunc f(b, c int) {}\n"), 0o600))
+	later := time.Now().Add(time.Second)
+	ass.NoError(t, os.Chtimes(filename, later, later))
+	f, err := loadFile(filename)
+	ass.NoError(t, err)
+	ass.True(t, f != files[0])
+	ass.Equal(t, []string{"b", "c"}, paramNames(filename, "f"))
+}
+
+func TestCallSites(t *testing.T) {
+	sites, err := CallSites("./testdata/imports.go", "github.com/rprtr258/assert")
+	ass.NoError(t, err)
+	ass.Equal(t, []CallSite{
+		{"imports.go", 16, "Equal", []string{"t", "x", "y"}},
+		{"imports.go", 17, "Equal", []string{"t", "x", "y"}},
+		{"imports.go", 19, "Equal", []string{"t", "x", "y"}},
+	}, sites)
+
+	// same line calls are ambiguous
+	sites, err = CallSites("./testdata/calls.go", "github.com/rprtr258/assert")
+	ass.NoError(t, err)
+	for _, site := range sites {
+		ass.NotEqual(t, 17, site.Line)
+	}
+}
+
+func TestLookupArgNamesTable(t *testing.T) {
+	const dir = "github.com/rprtr258/missing"
+	RegisterArgNames(dir, "github.com/rprtr258/assert", map[string][]string{
+		"a_test.go:12:Equal": {"t", "want", "got"},
+		"invalid":            {"x"},
+	})
+
+	// sources are not available, names are taken from table
+	got, ok := lookupArgNames(dir+"/a_test.go", 12, "github.com/rprtr258/assert", "Equal")
+	ass.True(t, ok)
+	ass.Equal(t, []string{"t", "want", "got"}, got)
+
+	_, ok = lookupArgNames(dir+"/a_test.go", 13, "github.com/rprtr258/assert", "Equal")
+	ass.False(t, ok)
+}
+
 func TestArgNamesBadFilename(t *testing.T) {
 	_, ok := argNames("BAD FILENAME", 0, "", "")
 	ass.False(t, ok)
@@ -385,7 +534,8 @@ func TestIsQCall(t *testing.T) {
 		3: {
 			expr: &ast.CallExpr{
 				Fun: &ast.SelectorExpr{
-					X: &ast.Ident{Name: "q"},
+					X:   &ast.Ident{Name: "q"},
+					Sel: &ast.Ident{Name: "Q"},
 				},
 			},
 			want: true,
@@ -412,9 +562,18 @@ func TestIsQCall(t *testing.T) {
 			},
 			want: false,
 		},
+		7: {
+			expr: &ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X:   &ast.Ident{Name: "q"},
+					Sel: &ast.Ident{Name: "R"},
+				},
+			},
+			want: false,
+		},
 	} {
 		t.Run(fmt.Sprintf("TEST %d", id), func(t *testing.T) {
-			ass.Equal(t, test.want, isFuncCall(test.expr, "q", "Q"))
+			ass.Equal(t, test.want, callMatcher{[]string{"q"}, true}.match(test.expr, "Q"))
 		})
 	}
 }
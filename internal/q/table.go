@@ -0,0 +1,142 @@
+package q
+
+import (
+	"cmp"
+	"go/ast"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallSite is call of package function with names of its arguments.
+type CallSite struct {
+	// File is base name of file with the call
+	File string
+	// Line is line of opening paren of the call
+	Line  int
+	Func  string
+	Names []string
+}
+
+// Key returns call site key used in generated tables, e.g. a_test.go:12:Equal.
+func (s CallSite) Key() string {
+	return s.File + ":" + strconv.Itoa(s.Line) + ":" + s.Func
+}
+
+// parseKey parses call site key returned by CallSite.Key.
+func parseKey(key string) (CallSite, bool) {
+	rest, funcName, ok := cutLast(key, ":")
+	if !ok {
+		return CallSite{}, false
+	}
+
+	file, lineStr, ok := cutLast(rest, ":")
+	if !ok {
+		return CallSite{}, false
+	}
+
+	line, err := strconv.Atoi(lineStr)
+	if err != nil {
+		return CallSite{}, false
+	}
+
+	return CallSite{File: file, Line: line, Func: funcName, Names: nil}, true
+}
+
+func cutLast(s, sep string) (before, after string, ok bool) {
+	i := strings.LastIndex(s, sep)
+	if i == -1 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// CallSites returns calls of exported functions from package with import path
+// pkgPath in file, sorted by line. Calls of the same function opening on the
+// same line are ambiguous and skipped, as argNames does.
+func CallSites(filename, pkgPath string) ([]CallSite, error) {
+	f, err := loadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := newCallMatcher(f.file, pkgPath)
+	sites := map[string]CallSite{}
+	ambiguous := map[string]bool{}
+	for line, calls := range f.calls {
+		for _, call := range calls {
+			funcName, ok := matcher.called(call)
+			if !ok || !ast.IsExported(funcName) {
+				continue
+			}
+
+			site := CallSite{
+				File:  path.Base(filename),
+				Line:  line,
+				Func:  funcName,
+				Names: callArgNames(call),
+			}
+			key := site.Key()
+			if _, ok := sites[key]; ok {
+				ambiguous[key] = true
+			}
+			sites[key] = site
+		}
+	}
+
+	res := make([]CallSite, 0, len(sites))
+	for key, site := range sites {
+		if !ambiguous[key] {
+			res = append(res, site)
+		}
+	}
+	slices.SortFunc(res, func(a, b CallSite) int {
+		return cmp.Or(
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Func, b.Func),
+		)
+	})
+	return res, nil
+}
+
+type tableKey struct {
+	file     string
+	line     int
+	pkgPath  string
+	funcName string
+}
+
+// _table maps call sites to names of their arguments, for binaries built
+// without sources available.
+var _table sync.Map
+
+// RegisterArgNames adds generated table of argument names of calls of
+// functions from package pkgPath, made in files in directory dir. Table maps
+// CallSite.Key of call sites to names of arguments, invalid keys are ignored.
+func RegisterArgNames(dir, pkgPath string, table map[string][]string) {
+	for key, names := range table {
+		site, ok := parseKey(key)
+		if !ok {
+			continue
+		}
+
+		_table.Store(tableKey{
+			file:     path.Join(dir, site.File),
+			line:     site.Line,
+			pkgPath:  pkgPath,
+			funcName: site.Func,
+		}, names)
+	}
+}
+
+// lookupArgNames returns argument names of call at filename and line from
+// registered tables, falling back to parsing source file.
+func lookupArgNames(filename string, line int, pkgPath, funcName string) ([]string, bool) {
+	if names, ok := _table.Load(tableKey{filename, line, pkgPath, funcName}); ok {
+		return slices.Clone(names.([]string)), true //nolint:forcetypeassert // only names are stored
+	}
+
+	return argNames(filename, line, pkgPath, funcName)
+}
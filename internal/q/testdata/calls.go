@@ -0,0 +1,23 @@
+// Calls in various layouts for argNames tests, line numbers matter.
+// File is not gofmt-ed on purpose to keep calls on the same line.
+package testdata
+
+import (
+	"testing"
+
+	"github.com/rprtr258/assert"
+)
+
+func TestCalls(t *testing.T) {
+	a, b := 1, 2
+
+	assert.
+		Equal(t, a, b)
+
+	assert.Equal(t, a, b); assert.Equal(t, b, a)
+
+	assert.Equal(t,
+		a,
+		b,
+	)
+}
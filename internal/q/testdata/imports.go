@@ -0,0 +1,20 @@
+// Calls of assert package imported under different names for argNames tests,
+// line numbers matter.
+package testdata
+
+import (
+	"testing"
+
+	a "github.com/rprtr258/assert"
+	. "github.com/rprtr258/assert"
+	assert "github.com/rprtr258/other/assert"
+)
+
+func TestImports(t *testing.T) {
+	x, y := 1, 2
+
+	a.Equal(t, x, y)
+	Equal(t, x, y)
+	assert.Equal(t, x, y)
+	a.Equal[int](t, x, y)
+}
@@ -0,0 +1,193 @@
+// Colour depth detection and downsampling of RGB mods
+package scuf
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ColorDepth int
+
+const (
+	// ColorDepth16 is basic 8 colours with their bright variants
+	ColorDepth16 ColorDepth = iota
+	// ColorDepth256 is xterm 256 colour palette
+	ColorDepth256
+	// ColorDepthTrueColor is 24-bit RGB
+	ColorDepthTrueColor
+)
+
+// Depth is colour depth of terminal, RGB mods are downsampled to it by String.
+// Detected from environment on startup, can be overridden.
+var Depth = DetectColorDepth()
+
+// DetectColorDepth guesses terminal colour depth using COLORTERM and TERM
+// environment variables.
+func DetectColorDepth() ColorDepth {
+	return detectColorDepth(os.Getenv("COLORTERM"), os.Getenv("TERM"))
+}
+
+func detectColorDepth(colorterm, term string) ColorDepth {
+	switch strings.ToLower(colorterm) {
+	case "truecolor", "24bit":
+		return ColorDepthTrueColor
+	}
+
+	switch {
+	case strings.Contains(term, "truecolor"),
+		strings.Contains(term, "24bit"),
+		strings.HasSuffix(term, "-direct"):
+		return ColorDepthTrueColor
+	case strings.Contains(term, "256color"):
+		return ColorDepth256
+	default:
+		return ColorDepth16
+	}
+}
+
+// _palette16 is RGB values of basic colours as rendered by xterm, index is
+// offset from FgBlack, indexes 8-15 are bright variants.
+var _palette16 = [16][3]uint8{
+	{0x00, 0x00, 0x00}, {0xcd, 0x00, 0x00}, {0x00, 0xcd, 0x00}, {0xcd, 0xcd, 0x00},
+	{0x00, 0x00, 0xee}, {0xcd, 0x00, 0xcd}, {0x00, 0xcd, 0xcd}, {0xe5, 0xe5, 0xe5},
+	{0x7f, 0x7f, 0x7f}, {0xff, 0x00, 0x00}, {0x00, 0xff, 0x00}, {0xff, 0xff, 0x00},
+	{0x5c, 0x5c, 0xff}, {0xff, 0x00, 0xff}, {0x00, 0xff, 0xff}, {0xff, 0xff, 0xff},
+}
+
+// _cubeLevels are channel values of 6x6x6 colour cube of 256 colour palette.
+var _cubeLevels = [6]uint8{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+
+func distance(a, b [3]uint8) int {
+	res := 0
+	for i := range a {
+		d := int(a[i]) - int(b[i])
+		res += d * d
+	}
+	return res
+}
+
+func nearestCubeLevel(c uint8) int {
+	best := 0
+	for i, level := range _cubeLevels {
+		if absDiff(c, level) < absDiff(c, _cubeLevels[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// to256 returns index of nearest colour in xterm 256 colour palette,
+// only cube and grayscale ramp are considered as basic colours are
+// configurable by terminal themes.
+func to256(rgb [3]uint8) int {
+	ri, gi, bi := nearestCubeLevel(rgb[0]), nearestCubeLevel(rgb[1]), nearestCubeLevel(rgb[2])
+	cube := [3]uint8{_cubeLevels[ri], _cubeLevels[gi], _cubeLevels[bi]}
+	best, bestDistance := 16+36*ri+6*gi+bi, distance(rgb, cube)
+
+	for i := range 24 {
+		level := uint8(8 + 10*i)
+		if d := distance(rgb, [3]uint8{level, level, level}); d < bestDistance {
+			best, bestDistance = 232+i, d
+		}
+	}
+	return best
+}
+
+// from256 returns RGB value of colour from xterm 256 colour palette.
+func from256(index int) [3]uint8 {
+	switch {
+	case index < 16:
+		return _palette16[index]
+	case index < 232:
+		index -= 16
+		return [3]uint8{_cubeLevels[index/36], _cubeLevels[index/6%6], _cubeLevels[index%6]}
+	default:
+		level := uint8(8 + 10*(index-232))
+		return [3]uint8{level, level, level}
+	}
+}
+
+// to16 returns index of nearest colour in basic 16 colour palette.
+func to16(rgb [3]uint8) int {
+	best := 0
+	for i, c := range _palette16 {
+		if distance(rgb, c) < distance(rgb, _palette16[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Downsample converts RGB and 256 colour parameters of mod to nearest colours
+// available with given depth. Other parameters are left as is.
+func Downsample(mod Mod, depth ColorDepth) Mod {
+	if depth == ColorDepthTrueColor || !strings.Contains(mod, "8;") {
+		return mod
+	}
+
+	params := strings.Split(mod, ";")
+	res := make([]string, 0, len(params))
+	for i := 0; i < len(params); i++ {
+		if params[i] != "38" && params[i] != "48" || i+1 >= len(params) {
+			res = append(res, params[i])
+			continue
+		}
+
+		background := params[i] == "48"
+		var rgb [3]uint8
+		switch {
+		case params[i+1] == "2" && i+4 < len(params):
+			for j := range rgb {
+				c, err := strconv.ParseUint(params[i+2+j], 10, 8)
+				if err != nil {
+					return mod
+				}
+				rgb[j] = uint8(c)
+			}
+			if depth == ColorDepth256 {
+				res = append(res, params[i], "5", strconv.Itoa(to256(rgb)))
+				i += 4
+				continue
+			}
+			i += 4
+		case params[i+1] == "5" && i+2 < len(params):
+			if depth == ColorDepth256 {
+				res = append(res, params[i:i+3]...)
+				i += 2
+				continue
+			}
+			index, err := strconv.ParseUint(params[i+2], 10, 8)
+			if err != nil {
+				return mod
+			}
+			rgb = from256(int(index))
+			i += 2
+		default:
+			res = append(res, params[i])
+			continue
+		}
+
+		res = append(res, basicColor(to16(rgb), background))
+	}
+	return strings.Join(res, ";")
+}
+
+// basicColor returns SGR parameter for colour from basic 16 colour palette.
+func basicColor(index int, background bool) string {
+	code := 30 + index
+	if index >= 8 { // bright variants
+		code = 90 + index - 8
+	}
+	if background {
+		code += 10
+	}
+	return strconv.Itoa(code)
+}
@@ -0,0 +1,210 @@
+// Rendering of escape sequences as HTML
+package scuf
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rprtr258/assert/internal/fun"
+)
+
+// HTMLStyle is CSS for classes used by HTML. Reverse video is approximated by
+// inverting colours, as classes cannot swap them.
+const HTMLStyle = `.sgr-bold{font-weight:bold}
+.sgr-faint{opacity:.6}
+.sgr-italic{font-style:italic}
+.sgr-underline{text-decoration:underline}
+.sgr-blink{animation:sgr-blink 1s step-end infinite}@keyframes sgr-blink{50%{visibility:hidden}}
+.sgr-reverse{filter:invert(1)}
+.sgr-crossout{text-decoration:line-through}
+.sgr-overline{text-decoration:overline}
+.sgr-fg-black{color:#000000}.sgr-fg-red{color:#cd0000}.sgr-fg-green{color:#00cd00}.sgr-fg-yellow{color:#cdcd00}
+.sgr-fg-blue{color:#0000ee}.sgr-fg-magenta{color:#cd00cd}.sgr-fg-cyan{color:#00cdcd}.sgr-fg-white{color:#e5e5e5}
+.sgr-fg-hi-black{color:#7f7f7f}.sgr-fg-hi-red{color:#ff0000}.sgr-fg-hi-green{color:#00ff00}.sgr-fg-hi-yellow{color:#ffff00}
+.sgr-fg-hi-blue{color:#5c5cff}.sgr-fg-hi-magenta{color:#ff00ff}.sgr-fg-hi-cyan{color:#00ffff}.sgr-fg-hi-white{color:#ffffff}
+.sgr-bg-black{background:#000000}.sgr-bg-red{background:#cd0000}.sgr-bg-green{background:#00cd00}.sgr-bg-yellow{background:#cdcd00}
+.sgr-bg-blue{background:#0000ee}.sgr-bg-magenta{background:#cd00cd}.sgr-bg-cyan{background:#00cdcd}.sgr-bg-white{background:#e5e5e5}
+.sgr-bg-hi-black{background:#7f7f7f}.sgr-bg-hi-red{background:#ff0000}.sgr-bg-hi-green{background:#00ff00}.sgr-bg-hi-yellow{background:#ffff00}
+.sgr-bg-hi-blue{background:#5c5cff}.sgr-bg-hi-magenta{background:#ff00ff}.sgr-bg-hi-cyan{background:#00ffff}.sgr-bg-hi-white{background:#ffffff}
+`
+
+var _colorNames = [8]string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+var _modClasses = map[string]string{
+	ModBold:      "sgr-bold",
+	ModFaint:     "sgr-faint",
+	ModItalic:    "sgr-italic",
+	ModUnderline: "sgr-underline",
+	ModBlink:     "sgr-blink",
+	ModReverse:   "sgr-reverse",
+	ModCrossout:  "sgr-crossout",
+	ModOverline:  "sgr-overline",
+}
+
+// _modResets are SGR parameters disabling modifiers.
+var _modResets = map[string][]string{
+	"22": {ModBold, ModFaint},
+	"23": {ModItalic},
+	"24": {ModUnderline},
+	"25": {ModBlink},
+	"27": {ModReverse},
+	"29": {ModCrossout},
+	"55": {ModOverline},
+}
+
+// sgrState is text style set by SGR sequences since last reset.
+type sgrState struct {
+	// mods are enabled modifiers, in order they were enabled
+	mods []string
+	// fg and bg are parameters of foreground and background colours, e.g.
+	// 31 or 38;5;196, if set
+	fg, bg []string
+}
+
+// apply updates style with SGR parameters. Later colours replace earlier
+// ones.
+func (st *sgrState) apply(params []string) {
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		code, _ := strconv.Atoi(param)
+		switch {
+		case param == ModReset || param == "":
+			*st = sgrState{}
+		case (code == 38 || code == 48) && i+1 < len(params):
+			// extended colour, its parameters must not be treated as separate ones
+			n := fun.Ternary(params[i+1] == "2", 5, 3)
+			colour := slices.Clone(params[i:min(i+n, len(params))])
+			i += n - 1
+			if code == 38 {
+				st.fg = colour
+			} else {
+				st.bg = colour
+			}
+		case code >= 30 && code <= 37 || code >= 90 && code <= 97:
+			st.fg = []string{param}
+		case code >= 40 && code <= 47 || code >= 100 && code <= 107:
+			st.bg = []string{param}
+		case code == 39:
+			st.fg = nil
+		case code == 49:
+			st.bg = nil
+		case _modResets[param] != nil:
+			st.mods = slices.DeleteFunc(st.mods, func(mod string) bool {
+				return slices.Contains(_modResets[param], mod)
+			})
+		case !slices.Contains(st.mods, param):
+			st.mods = append(st.mods, param)
+		}
+	}
+}
+
+// params returns SGR parameters setting style.
+func (st sgrState) params() []string {
+	return slices.Concat(st.fg, st.bg, st.mods)
+}
+
+// htmlAttrs converts SGR parameters to HTML class and style attributes.
+func htmlAttrs(params []string) (classes, styles []string) {
+	for i := 0; i < len(params); i++ {
+		param := params[i]
+		if class, ok := _modClasses[param]; ok {
+			classes = append(classes, class)
+			continue
+		}
+
+		code, err := strconv.Atoi(param)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case code >= 30 && code <= 37:
+			classes = append(classes, "sgr-fg-"+_colorNames[code-30])
+		case code >= 90 && code <= 97:
+			classes = append(classes, "sgr-fg-hi-"+_colorNames[code-90])
+		case code >= 40 && code <= 47:
+			classes = append(classes, "sgr-bg-"+_colorNames[code-40])
+		case code >= 100 && code <= 107:
+			classes = append(classes, "sgr-bg-hi-"+_colorNames[code-100])
+		case (code == 38 || code == 48) && i+1 < len(params):
+			property := "color"
+			if code == 48 {
+				property = "background"
+			}
+
+			var rgb [3]uint8
+			switch {
+			case params[i+1] == "2" && i+4 < len(params):
+				for j := range rgb {
+					c, _ := strconv.ParseUint(params[i+2+j], 10, 8)
+					rgb[j] = uint8(c)
+				}
+				i += 4
+			case params[i+1] == "5" && i+2 < len(params):
+				index, _ := strconv.ParseUint(params[i+2], 10, 8)
+				rgb = from256(int(index))
+				i += 2
+			default:
+				continue
+			}
+			styles = append(styles, fmt.Sprintf("%s:#%02x%02x%02x", property, rgb[0], rgb[1], rgb[2]))
+		}
+	}
+	return classes, styles
+}
+
+// HTML converts s with SGR escape sequences to HTML, where styled text is
+// wrapped into span elements with classes from HTMLStyle. Other escape
+// sequences are dropped, text is escaped.
+func HTML(s string) string {
+	var sb strings.Builder
+	var style sgrState
+	spanOpened := false
+	for s != "" {
+		n := escapeLen(s)
+		if n == 0 {
+			i := strings.IndexByte(s[1:], _esc) + 1
+			if i == 0 {
+				i = len(s)
+			}
+			sb.WriteString(html.EscapeString(s[:i]))
+			s = s[i:]
+			continue
+		}
+
+		seq := s[:n]
+		s = s[n:]
+		if !strings.HasPrefix(seq, _csi) || !strings.HasSuffix(seq, "m") {
+			continue
+		}
+
+		style.apply(strings.Split(seq[len(_csi):len(seq)-1], ";"))
+
+		if spanOpened {
+			sb.WriteString("</span>")
+			spanOpened = false
+		}
+
+		classes, styles := htmlAttrs(style.params())
+		if len(classes) == 0 && len(styles) == 0 {
+			continue
+		}
+
+		sb.WriteString("<span")
+		if len(classes) > 0 {
+			sb.WriteString(` class="` + strings.Join(classes, " ") + `"`)
+		}
+		if len(styles) > 0 {
+			sb.WriteString(` style="` + strings.Join(styles, ";") + `"`)
+		}
+		sb.WriteString(">")
+		spanOpened = true
+	}
+	if spanOpened {
+		sb.WriteString("</span>")
+	}
+	return sb.String()
+}
@@ -64,8 +64,9 @@ func FgRGB(r, g, b uint8) Mod {
 	return fmt.Sprintf("38;2;%d;%d;%d", r, g, b)
 }
 
+// String wraps s into mod escape sequences, mod colours are downsampled to Depth.
 func String(s string, mod Mod) string {
-	return _csi + mod + "m" +
+	return _csi + Downsample(mod, Depth) + "m" +
 		s +
 		_csi + ModReset + "m"
 }
@@ -0,0 +1,104 @@
+package scuf
+
+import (
+	"testing"
+
+	"github.com/rprtr258/assert/internal/ass"
+)
+
+func TestDetectColorDepth(t *testing.T) {
+	for _, test := range []struct {
+		colorterm, term string
+		want            ColorDepth
+	}{
+		{"truecolor", "xterm-256color", ColorDepthTrueColor},
+		{"24bit", "", ColorDepthTrueColor},
+		{"", "xterm-direct", ColorDepthTrueColor},
+		{"", "xterm-256color", ColorDepth256},
+		{"", "screen-256color", ColorDepth256},
+		{"", "xterm", ColorDepth16},
+		{"", "", ColorDepth16},
+	} {
+		t.Run(test.colorterm+"/"+test.term, func(t *testing.T) {
+			ass.Equal(t, test.want, detectColorDepth(test.colorterm, test.term))
+		})
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	for _, test := range []struct {
+		mod   Mod
+		depth ColorDepth
+		want  Mod
+	}{
+		{FgRGB(0xff, 0x40, 0x53), ColorDepthTrueColor, "38;2;255;64;83"},
+		{FgRGB(0xff, 0x40, 0x53), ColorDepth256, "38;5;203"},
+		{FgRGB(0xff, 0x40, 0x53), ColorDepth16, FgHiRed},
+		{FgRGB(0x96, 0xf7, 0x59), ColorDepth256, "38;5;119"},
+		{FgRGB(0x96, 0xf7, 0x59), ColorDepth16, FgYellow},
+		{FgRGB(0x80, 0x80, 0x80), ColorDepth256, "38;5;244"},
+		{"48;2;0;0;0", ColorDepth16, BgBlack},
+		{"38;5;196", ColorDepth256, "38;5;196"},
+		{"38;5;196", ColorDepth16, FgHiRed},
+		{ModBold + ";" + FgRGB(0, 0, 0xee) + ";" + ModUnderline, ColorDepth16, "1;34;4"},
+		{FgRed + ";" + ModBold, ColorDepth16, "31;1"},
+	} {
+		t.Run(test.mod, func(t *testing.T) {
+			ass.Equal(t, test.want, Downsample(test.mod, test.depth))
+		})
+	}
+}
+
+func TestWidth(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		stripped string
+		width    int
+	}{
+		{"abc", "abc", 3},
+		{String("abc", FgRed), "abc", 3},
+		{String("a", FgRGB(1, 2, 3)) + "b" + String("c", ModBold), "abc", 3},
+		{"日本語", "日本語", 6},
+		{String("日本", FgGreen) + "x", "日本x", 5},
+		{"é", "é", 1},
+		{"🚀!", "🚀!", 3},
+		{_osc + "8;;https://example.com" + _stringTerminator + "link" + _osc + "8;;\a", "link", 4},
+	} {
+		t.Run(test.stripped, func(t *testing.T) {
+			ass.Equal(t, test.stripped, Strip(test.s))
+			ass.Equal(t, test.width, Width(test.s))
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{"a < b", "a &lt; b"},
+		{String("x", FgRed), `<span class="sgr-fg-red">x</span>`},
+		{String("x", FgBlue+";"+ModBold) + "y", `<span class="sgr-fg-blue sgr-bold">x</span>y`},
+		{_csi + "38;2;0;0;0m" + "x" + _csi + ModReset + "m", `<span style="color:#000000">x</span>`},
+		{_csi + "48;5;196m" + "x" + _csi + ModReset + "m", `<span style="background:#ff0000">x</span>`},
+		{_csi + FgRed + "m" + "a" + _csi + ModBold + "m" + "b" + _csi + ModReset + "m", `<span class="sgr-fg-red">a</span><span class="sgr-fg-red sgr-bold">b</span>`},
+		{String("a"+String("b", FgGreen)+"c", FgRed), `<span class="sgr-fg-red">a</span><span class="sgr-fg-green">b</span>c`},
+		{_csi + FgRed + ";" + BgBlue + ";" + ModBold + "m" + "a" + _csi + "39;22m" + "b" + _csi + "49m" + "c", `<span class="sgr-fg-red sgr-bg-blue sgr-bold">a</span><span class="sgr-bg-blue">b</span>c`},
+		{_csi + "38;5;196m" + "a" + _csi + FgGreen + "m" + "b", `<span style="color:#ff0000">a</span><span class="sgr-fg-green">b</span>`},
+	} {
+		t.Run(test.want, func(t *testing.T) {
+			ass.Equal(t, test.want, HTML(test.s))
+		})
+	}
+}
+
+func TestHTMLStyle(t *testing.T) {
+	for _, class := range _modClasses {
+		ass.SContains(t, "."+class+"{", HTMLStyle)
+	}
+	for _, name := range _colorNames {
+		for _, prefix := range []string{"fg-", "fg-hi-", "bg-", "bg-hi-"} {
+			ass.SContains(t, ".sgr-"+prefix+name+"{", HTMLStyle)
+		}
+	}
+}
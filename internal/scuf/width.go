@@ -0,0 +1,94 @@
+// Measuring visible width of strings with escape sequences
+package scuf
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// escapeLen returns length of escape sequence at the start of s, or 0 if s
+// does not start with one.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != _esc {
+		return 0
+	}
+
+	switch {
+	case strings.HasPrefix(s, _csi):
+		// parameters and intermediate bytes, then final byte in range 0x40-0x7e
+		for i := len(_csi); i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case strings.HasPrefix(s, _osc):
+		// terminated by either BEL or String Terminator
+		for i := len(_osc); i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if strings.HasPrefix(s[i:], _stringTerminator) {
+				return i + len(_stringTerminator)
+			}
+		}
+		return len(s)
+	default:
+		return 2 //nolint:mnd // two-byte escape sequence
+	}
+}
+
+// Strip removes escape sequences from s.
+func Strip(s string) string {
+	if strings.IndexByte(s, _esc) == -1 {
+		return s
+	}
+
+	var sb strings.Builder
+	for s != "" {
+		if n := escapeLen(s); n > 0 {
+			s = s[n:]
+			continue
+		}
+
+		i := strings.IndexByte(s[1:], _esc) + 1
+		if i == 0 {
+			i = len(s)
+		}
+		sb.WriteString(s[:i])
+		s = s[i:]
+	}
+	return sb.String()
+}
+
+// RuneWidth returns number of terminal cells occupied by r: 0 for control and
+// combining characters, 2 for wide East Asian characters and emoji, 1 otherwise.
+func RuneWidth(r rune) int {
+	switch {
+	case r == utf8.RuneError:
+		return 1
+	case r < ' ', r == 0x7f, // control characters
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf): // combining marks and format characters like ZWJ
+		return 0
+	}
+
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2 //nolint:mnd // wide character
+	default:
+		return 1
+	}
+}
+
+// Width returns number of terminal cells occupied by s, escape sequences are
+// not counted. s is assumed to be single line.
+func Width(s string) int {
+	res := 0
+	for _, r := range Strip(s) {
+		res += RuneWidth(r)
+	}
+	return res
+}
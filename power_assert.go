@@ -1,25 +1,68 @@
 package assert
 
 import (
-	"log"
-	"testing"
+	"os"
+	"runtime"
+	"sync"
 )
 
-// TODO: fuse once
-func fuse(tb testing.TB) {
+// _fuse is result of rerun of package tests with rewritten Assert and Require
+// calls, which is done once per test binary.
+var _fuse = struct {
+	once   sync.Once
+	result rerunResult
+	err    error
+}{}
+
+// fuse reruns tests of package calling Assert or Require with calls rewritten
+// and skips current test, or fails it with its output if it failed in rerun.
+func fuse(tb T) {
 	tb.Helper()
-	if err := run(); err != nil {
-		log.Fatalln(err.Error())
+	if os.Getenv("ASSERT_MODULE_DIR") != "" {
+		tb.Fatal("Assert or Require call was not rewritten, call it directly with a predicate expression")
+	}
+
+	_, file, _, ok := runtime.Caller(2) // Assert/Require -> fuse
+	_fuse.once.Do(func() {
+		if !ok {
+			_fuse.err = errUnknownCaller
+			return
+		}
+		_fuse.result, _fuse.err = run(file)
+	})
+	if _fuse.err != nil {
+		tb.Fatal(_fuse.err)
+	}
+
+	inner, _, _ := unwrap(tb)
+	inner.Helper()
+	name := ""
+	if named, ok := inner.(interface{ Name() string }); ok {
+		name = named.Name()
+	}
+
+	output := _fuse.result.output(name)
+	if _fuse.result.failed[name] {
+		inner.Fatal("failed in rerun with rewritten Assert and Require calls:\n" + output)
+	}
+	if skipper, ok := inner.(interface{ Skip(args ...any) }); ok {
+		if output != "" {
+			skipper.Skip("passed in rerun with rewritten Assert and Require calls:\n" + output)
+		}
+		skipper.Skip("passed in rerun with rewritten Assert and Require calls")
 	}
-	tb.SkipNow()
 }
 
-func Assert(tb testing.TB, cond bool) {
+// Assert fails test if cond is false, showing values of sub-expressions of
+// cond. Optional msgAndArgs are format and its arguments of message shown
+// above them.
+func Assert(tb T, cond bool, msgAndArgs ...any) {
 	tb.Helper()
 	fuse(tb)
 }
 
-func Require(tb testing.TB, cond bool) {
+// Require is like Assert, but stops test on failure.
+func Require(tb T, cond bool, msgAndArgs ...any) {
 	tb.Helper()
 	fuse(tb)
 }
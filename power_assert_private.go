@@ -3,36 +3,30 @@ package assert
 // BEHOLD: api for generated code, do not use
 
 import (
-	"bytes"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/printer"
-	"go/token"
 	"io"
-	"io/fs"
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	rtdebug "runtime/debug"
 	"slices"
+	"sort"
 	"strconv"
 	"strings"
-	"testing"
 
-	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
 
+	"github.com/rprtr258/assert/internal/powerassert"
 	"github.com/rprtr258/assert/internal/pp"
-)
-
-var (
-	pkg     = ast.NewIdent("assert")
-	pkgRoot = &ast.SelectorExpr{
-		X:   pkg,
-		Sel: ast.NewIdent("SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__"),
-	}
+	"github.com/rprtr258/assert/internal/q"
+	"github.com/rprtr258/assert/internal/scuf"
 )
 
 type shit struct {
@@ -42,26 +36,42 @@ type shit struct {
 	ZZZSnapshot          bool
 	ZZZCapturedSnapshots []string
 
-	ZZZNew     func(exprStr string) *assertData
+	ZZZNew func(exprStr string, lazy [][3]int, equal [][8]int) *assertData
 }
 
 var SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__ = shit{
-	ZZZNew: func(exprStr string) *assertData {
-		return &assertData{exprStr: exprStr}
+	ZZZNew: func(exprStr string, lazy [][3]int, equal [][8]int) *assertData {
+		return &assertData{exprs: nil, exprStr: exprStr, lazy: lazy, equal: equal}
 	},
 }
 
-func (shit) ZZZAssert(tb testing.TB, assertData *assertData, cond bool) {
-	tb.Helper()
-	assert(tb, assertData, cond, "assert", tb.Fail)
+func (shit) ZZZAssert(tb T, assertData *assertData, cond bool, msgAndArgs ...any) {
+	inner, _, _ := unwrap(tb) // Helper of wrapper would mark wrapper method
+	inner.Helper()
+	assert(tb, assertData, cond, "assert", false, msgAndArgs)
 }
 
-func (shit) ZZZRequire(tb testing.TB, assertData *assertData, cond bool) {
-	tb.Helper()
-	assert(tb, assertData, cond, "require", tb.FailNow)
+func (shit) ZZZRequire(tb T, assertData *assertData, cond bool, msgAndArgs ...any) {
+	inner, _, _ := unwrap(tb) // Helper of wrapper would mark wrapper method
+	inner.Helper()
+	assert(tb, assertData, cond, "require", true, msgAndArgs)
+}
+
+// ZZZRegisterArgNames registers table of argument names of assert calls
+// generated by cmd/argnames, so that names are shown when sources are not
+// available. Must be called from generated file in the same directory as the
+// calls.
+func (shit) ZZZRegisterArgNames(table map[string][]string) {
+	_, file, _, ok := runtime.Caller(1)
+	if !ok {
+		return
+	}
+
+	q.RegisterArgNames(path.Dir(file), _pkgPath, table)
 }
 
 type expr struct {
+	value    any
 	valueStr string
 	position int
 }
@@ -69,386 +79,540 @@ type expr struct {
 type assertData struct {
 	exprs   []expr
 	exprStr string
+	// lazy are operands which may be not evaluated due to short-circuiting
+	// of && and || operators, as positions of their values and their spans
+	lazy [][3]int
+	// equal are == comparisons and comparison deciding predicate, as
+	// positions of their values and values of their operands, spans of
+	// operands and 1 for deciding comparison
+	equal [][8]int
 }
 
 func (shit) ZZZAdd[T any](a *assertData, position int, value T) T {
-	// TODO: pretty print in one line, no trailing commas
-	s := pp.Sprint(value)
-	s = strings.ReplaceAll(s, "\n    ", "")
-	s = strings.ReplaceAll(s, "\n", "")
-	s = strings.ReplaceAll(s, ",}", "}")
-
-	a.exprs = append(a.exprs, expr{s, position})
+	a.exprs = append(a.exprs, expr{value, pp.SprintLine(value), position})
 	return value
 }
 
-func assert(tb testing.TB, assertData *assertData, cond bool, fn string, onfail func()) {
-	tb.Helper()
-	if cond {
-		return
+// ZZZAddBefore captures value at position and returns a, so that value is
+// captured before expression using a is evaluated.
+func (shit) ZZZAddBefore[T any](a *assertData, position int, value T) *assertData {
+	a.exprs = append(a.exprs, expr{value, pp.SprintLine(value), position})
+	return a
+}
+
+const _notEvaluated = "<not evaluated>"
+
+// values returns captured values and not evaluated operands sorted by
+// position. Only outermost not evaluated operands are returned.
+func (a *assertData) values() []expr {
+	evaluated := func(position int) bool {
+		return slices.ContainsFunc(a.exprs, func(e expr) bool { return e.position == position })
+	}
+
+	res := slices.Clone(a.exprs)
+	for _, operand := range a.lazy {
+		position := operand[0]
+		if evaluated(position) || slices.ContainsFunc(a.lazy, func(outer [3]int) bool {
+			return outer != operand && outer[1] <= position && position < outer[2] && !evaluated(outer[0])
+		}) {
+			continue
+		}
+		res = append(res, expr{nil, _notEvaluated, position})
 	}
 
-	slices.SortFunc(assertData.exprs, func(a, b expr) int {
+	slices.SortStableFunc(res, func(a, b expr) int {
 		return a.position - b.position
 	})
+	return res
+}
 
-	var s strings.Builder
-	s.WriteString(assertData.exprStr)
-	s.WriteString("\n")
-	for i, expr := range assertData.exprs {
-		n := expr.position
-		if i > 0 {
-			n -= assertData.exprs[i-1].position + 1
-		}
-		s.WriteString(strings.Repeat(" ", n))
-		s.WriteString("^")
+// value returns value captured at position.
+func (a *assertData) value(position int) (any, bool) {
+	i := slices.IndexFunc(a.exprs, func(e expr) bool { return e.position == position })
+	if i == -1 {
+		return nil, false
 	}
-	for i, e := range slices.Backward(assertData.exprs) {
-		s.WriteString("\n")
-		for j := 0; j <= i; j++ {
-			n := assertData.exprs[j].position
-			if j > 0 {
-				n -= assertData.exprs[j-1].position + 1
-			}
-			s.WriteString(strings.Repeat(" ", n))
-			if j < i {
-				s.WriteString("|")
-			} else {
-				s.WriteString(e.valueStr)
+	return a.exprs[i].value, true
+}
+
+// diffs returns diffs of operands of false == comparisons of structs and
+// arrays, and of top comparison deciding predicate. Left operand is considered
+// actual value, right one is expected.
+func (a *assertData) diffs(cfg Config) []labeledContent {
+	var res []labeledContent
+	for _, eq := range a.equal {
+		position, xPosition, yPosition := eq[0], eq[1], eq[2]
+		xStart, xEnd, yStart, yEnd := eq[3], eq[4], eq[5], eq[6]
+		top := eq[7] == 1
+
+		result, ok := a.value(position)
+		equal, isBool := result.(bool)
+		if !ok || !isBool {
+			continue
+		}
+		if strings.HasPrefix(a.exprStr[position:], "!=") {
+			equal = !equal
+		}
+
+		actual, okX := a.value(xPosition)
+		expected, okY := a.value(yPosition)
+		if !okX || !okY {
+			continue
+		}
+
+		expectedName := strings.Join(strings.Fields(a.exprStr[yStart:yEnd]), " ")
+		actualName := strings.Join(strings.Fields(a.exprStr[xStart:xEnd]), " ")
+		switch {
+		case !equal && (sameKind(expected, actual, reflect.Struct, reflect.Array) ||
+			top && sameKind(expected, actual, _structuredKinds...)):
+			lines, ok := collectDiff(expected, actual)
+			if !ok {
+				continue
 			}
+
+			res = append(res, labeledContent{
+				scuf.String("Not equal", cfg.Theme.Label),
+				renderDiff(cfg, expectedName, actualName, slices.Values(lines)),
+			})
+		case equal && top && sameKind(expected, actual, _structuredKinds...):
+			res = append(res, labeledContent{
+				scuf.String("Equal", cfg.Theme.Label),
+				renderEqual(cfg, expectedName, actualName, expected),
+			})
 		}
 	}
+	return res
+}
 
-	out := fn + " failed:\n" + s.String()
-	if SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZSnapshot {
-		SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZCapturedSnapshots = append(SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZCapturedSnapshots, out)
-		return
+// _structuredKinds are kinds of values, diff of which tells more than their
+// captured values.
+var _structuredKinds = []reflect.Kind{reflect.Struct, reflect.Array, reflect.Slice, reflect.Map, reflect.String}
+
+// sameKind returns true if values are of the same type of one of kinds.
+func sameKind(expected, actual any, kinds ...reflect.Kind) bool {
+	typ := reflect.TypeOf(expected)
+	if typ == nil || typ != reflect.TypeOf(actual) {
+		return false
 	}
-	defer onfail()
 
-	// TODO: reports wrong line number, fix it
-	tb.Errorf("%s", out)
+	return slices.Contains(kinds, typ.Kind())
 }
 
-const debug = false // TODO: make configurable, default to false
+// collectDiff returns diff of values, or false if their types are not
+// supported by diff.
+func collectDiff(expected, actual any) (lines []diffLine, ok bool) {
+	defer func() {
+		if recover() != nil {
+			lines, ok = nil, false
+		}
+	}()
 
-func debugf(format string, args ...any) {
-	if !debug {
-		return
-	}
-	log.Printf("[DEBUG] "+format, args...)
+	return slices.Collect(diffImpl("", expected, actual)), true
 }
 
-func sprintCode(n ast.Node) string {
-	buf := &bytes.Buffer{}
-	_ = printer.Fprint(buf, token.NewFileSet(), n)
-	return buf.String()
+// sourceLine is line of predicate source shown in diagram.
+type sourceLine struct {
+	// start and end are offsets of line in predicate source, with common
+	// indentation of continuation lines removed
+	start, end int
+	text       string
 }
 
-func dumpExpr(n ast.Expr, pos token.Pos) ast.Expr {
-	return &ast.CallExpr{
-		Fun: &ast.SelectorExpr{
-			X:   pkgRoot,
-			Sel: ast.NewIdent("ZZZAdd"),
-		},
-		Args: []ast.Expr{
-			ast.NewIdent("zzz"),
-			&ast.BasicLit{
-				Kind:  token.INT,
-				Value: strconv.Itoa(int(pos)),
-			},
-			n,
-		},
-	}
+// expandTabs replaces tabs with spaces, so that columns are the same in any
+// terminal.
+func expandTabs(s string) string {
+	return strings.ReplaceAll(s, "\t", "    ")
 }
 
-func rewriteExpr(n ast.Expr, offset token.Pos) ast.Expr {
-	switch n := n.(type) {
-	case nil:
-		return nil
-	case *ast.BasicLit:
-		return n
-	case *ast.ArrayType, *ast.StructType, *ast.FuncType, *ast.InterfaceType, *ast.MapType, *ast.ChanType:
-		return n
-	case *ast.Ident:
-		switch n.Name {
-		case "false", "true",
-			"nil", "string", "byte", "uintptr",
-			"int", "int8", "int16", "int32", "int64",
-			"uint", "uint8", "uint16", "uint32", "uint64",
-			"float32", "float64",
-			"complex64", "complex128":
-			return n
-		default:
-			return dumpExpr(n, n.Pos()-offset)
-		}
-	case *ast.CompositeLit:
-		for i, e := range n.Elts {
-			n.Elts[i] = rewriteExpr(e, offset)
+// sourceLines splits predicate source into lines, removing common indentation
+// of continuation lines.
+func sourceLines(exprStr string) []sourceLine {
+	texts := strings.Split(exprStr, "\n")
+
+	var indent *string
+	for _, text := range texts[1:] {
+		if strings.TrimSpace(text) == "" {
+			continue
 		}
-		return dumpExpr(n, n.Pos()-offset)
-	case *ast.SelectorExpr:
-		n.X = rewriteExpr(n.X, offset)
-		return dumpExpr(n, n.Sel.NamePos-offset)
-	case *ast.ParenExpr: // TODO: breaks position if optional, see (1+1) == 1 example
-		n.X = rewriteExpr(n.X, offset)
-		return dumpExpr(n, n.Lparen-offset)
-	case *ast.SliceExpr:
-		n.Low = rewriteExpr(n.Low, offset)
-		n.High = rewriteExpr(n.High, offset)
-		n.Max = rewriteExpr(n.Max, offset)
-		n.X = rewriteExpr(n.X, offset)
-		return dumpExpr(n, n.Lbrack-offset)
-	case *ast.IndexExpr:
-		n.Index = rewriteExpr(n.Index, offset)
-		n.X = rewriteExpr(n.X, offset)
-		return dumpExpr(n, n.Lbrack-offset)
-	case *ast.UnaryExpr:
-		if n.Op == token.AND {
-			// TODO: actually invalid, see &s == &s example, which fails due to taking address of copies of s
-			// needs testing
-			return dumpExpr(n, n.OpPos-offset)
+
+		lead := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		if indent != nil {
+			lead = lead[:commonPrefixLen(*indent, lead)]
 		}
-		n.X = rewriteExpr(n.X, offset)
-		return dumpExpr(n, n.OpPos-offset)
-	case *ast.BinaryExpr:
-		n.X = rewriteExpr(n.X, offset)
-		n.Y = rewriteExpr(n.Y, offset)
-		return dumpExpr(n, n.OpPos-offset)
-	case *ast.CallExpr:
-		for i, e := range n.Args {
-			n.Args[i] = rewriteExpr(e, offset)
+		indent = &lead
+	}
+
+	res := make([]sourceLine, len(texts))
+	offset := 0
+	for i, text := range texts {
+		start := offset
+		if i > 0 && indent != nil && strings.HasPrefix(text, *indent) {
+			start += len(*indent)
 		}
-		return dumpExpr(n, n.Pos()-offset)
-	case *ast.StarExpr:
-		n.X = rewriteExpr(n.X, offset)
-		return dumpExpr(n, n.Star-offset)
-	case *ast.BadExpr, *ast.FuncLit:
-		return n
-	case *ast.KeyValueExpr:
-		n.Key = rewriteExpr(n.Key, offset)
-		n.Value = rewriteExpr(n.Value, offset)
-		return n
-	default:
-		log.Fatalf("unsupported expr type %T", n)
-	}
-	panic("unreachable")
+		end := offset + len(text)
+		res[i] = sourceLine{start: start, end: end, text: expandTabs(exprStr[start:end])}
+		offset = end + len("\n")
+	}
+	return res
 }
 
-func getModuleDir() (string, error) {
-	// On the second (rewritten) pass we run from a temp copy of the module, so
-	// runtime.Caller would point at the copy. The first pass records the real
-	// module dir in ASSERT_MODULE_DIR; prefer it when set.
-	if dir := os.Getenv("ASSERT_MODULE_DIR"); dir != "" {
-		return dir, nil
+// renderDiagram renders predicate source with values captured in it, each
+// under its own line. Values not fitting into width are replaced with
+// references to legend below diagram. Width of 0 disables limit.
+func renderDiagram(exprStr string, exprs []expr, width int) string {
+	lines := sourceLines(exprStr)
+
+	// positions are byte offsets, convert them to lines and display columns
+	lineOf := make([]int, len(exprs))
+	columns := make([]int, len(exprs))
+	for i, e := range exprs {
+		lineOf[i] = max(sort.Search(len(lines), func(j int) bool { return lines[j].start > e.position })-1, 0)
+		line := lines[lineOf[i]]
+		columns[i] = scuf.Width(expandTabs(exprStr[line.start:min(max(e.position, line.start), line.end)]))
 	}
-	// os.Getwd does not cut it, since tests are being run from a temp dir using temporary executable
-	// so we have to do caller getting trickery and extract module path the hard way
-	_, file, _, ok := runtime.Caller(4) // Assert/Require -> fuse -> run -> getModuleDir
-	if !ok {
-		return "", errors.New("could not get caller, check sources are available")
+
+	// values not fitting are moved to legend, numbered in source order
+	values := make([]string, len(exprs))
+	var legend []string
+	for i, e := range exprs {
+		values[i] = e.valueStr
+		if width > 0 && columns[i]+scuf.Width(e.valueStr) > width {
+			legend = append(legend, e.valueStr)
+			values[i] = "[" + strconv.Itoa(len(legend)) + "]"
+		}
 	}
 
-	dir := filepath.Dir(file)
-	for {
-		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
-			break
+	var s strings.Builder
+	for l, line := range lines {
+		if l > 0 {
+			s.WriteString("\n")
 		}
+		s.WriteString(line.text)
 
-		if dir == "/" {
-			return "", errors.New("module directory not found")
+		first := slices.Index(lineOf, l)
+		if first == -1 {
+			continue
+		}
+		last := first
+		for last+1 < len(exprs) && lineOf[last+1] == l {
+			last++
 		}
 
-		dir = filepath.Dir(dir)
+		s.WriteString("\n")
+		for i := first; i <= last; i++ {
+			n := columns[i]
+			if i > first {
+				n -= columns[i-1] + 1
+			}
+			s.WriteString(strings.Repeat(" ", max(n, 0)))
+			s.WriteString("^")
+		}
+		for i := last; i >= first; i-- {
+			s.WriteString("\n")
+			for j := first; j <= i; j++ {
+				n := columns[j]
+				if j > first {
+					n -= columns[j-1] + 1
+				}
+				s.WriteString(strings.Repeat(" ", max(n, 0)))
+				if j < i {
+					s.WriteString("|")
+				} else {
+					s.WriteString(values[i])
+				}
+			}
+		}
 	}
-	return dir, nil
-}
 
-func run() error {
-	moduleDir, err := getModuleDir()
-	debugf("module dir %s", moduleDir)
+	if len(legend) > 0 {
+		s.WriteString("\n")
+		for i, value := range legend {
+			s.WriteString("\n[" + strconv.Itoa(i+1) + "] " + value)
+		}
+	}
+	return s.String()
+}
 
-	tmpDir, err := os.MkdirTemp("", "assert.*")
-	if err != nil {
-		return fmt.Errorf("create temp dir: %w", err)
+// message returns optional message given as format and its arguments.
+func message(msgAndArgs []any) string {
+	if len(msgAndArgs) == 0 {
+		return ""
 	}
-	if !debug {
-		defer os.RemoveAll(tmpDir)
+
+	if format, ok := msgAndArgs[0].(string); ok {
+		if len(msgAndArgs) == 1 {
+			return format
+		}
+		return fmt.Sprintf(format, msgAndArgs[1:]...)
 	}
-	debugf("temp dir %s created", tmpDir)
+	return fmt.Sprint(msgAndArgs...)
+}
 
-	// TODO: copy _test.go files, link everything besides
-	if err := os.CopyFS(tmpDir, os.DirFS(moduleDir)); err != nil {
-		return fmt.Errorf("copy project to temp dir: %w", err)
+// assert reports failure of predicate captured in assertData, with message
+// and context of tb wrapped by Wrap or Must shown above diagram. Test is
+// stopped if must is true or tb is wrapped by Must.
+func assert(tb T, assertData *assertData, cond bool, fn string, must bool, msgAndArgs []any) {
+	inner, wrappedMust, context := unwrap(tb)
+	inner.Helper()
+	if cond {
+		return
 	}
 
-	if err := os.Chdir(tmpDir); err != nil {
-		return fmt.Errorf("chdir to temp dir: %w", err)
+	cfg := GetConfig(inner)
+	diagram := renderDiagram(assertData.exprStr, assertData.values(), cfg.DiagramWidth)
+	diffs := assertData.diffs(cfg)
+	if msg := message(msgAndArgs); msg != "" {
+		context = withMessage(context, msg)
 	}
 
-	testfiles := []string{}
-	if err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() || !strings.HasSuffix(path, "_test.go") {
-			return err
+	if SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZSnapshot {
+		var out strings.Builder
+		for _, c := range context {
+			out.WriteString(c.label + ":\n" + c.content + "\n")
+		}
+		out.WriteString(fn + " failed:\n" + diagram)
+		for _, d := range diffs {
+			out.WriteString("\n" + d.label + ":\n" + d.content)
 		}
+		SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZCapturedSnapshots = append(SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZCapturedSnapshots, out.String())
+		return
+	}
+	if must || wrappedMust {
+		defer inner.FailNow()
+	}
+
+	lines := append(context, labeledContent{scuf.String(fn+" failed", cfg.Theme.Label), diagram})
+	fail(inner, append(lines, diffs...))
+}
 
-		testfiles = append(testfiles, path)
+const debug = false // TODO: make configurable, default to false
 
-		return nil
-	}); err != nil {
-		return fmt.Errorf("collect test files: %w", err)
+func debugf(format string, args ...any) {
+	if !debug {
+		return
 	}
+	log.Printf("[DEBUG] "+format, args...)
+}
 
-	for _, fileRelPath := range testfiles {
-		ff, err := os.Open(fileRelPath)
-		if err != nil {
-			return fmt.Errorf("open test file %s: %w", fileRelPath, err)
-		}
-		stat, err := ff.Stat()
-		if err != nil {
-			return fmt.Errorf("stat test file %s: %w", fileRelPath, err)
-		}
-		f, err := io.ReadAll(ff)
-		if err != nil {
-			return fmt.Errorf("read test file %s: %w", fileRelPath, err)
-		}
-		ff.Close()
+var errUnknownCaller = errors.New("could not get caller, check sources are available")
 
-		root, err := parser.ParseFile(token.NewFileSet(), fileRelPath, f, 0)
-		if err != nil {
-			return fmt.Errorf("parse test file %s: %w", fileRelPath, err)
+// getModuleDir returns directory of module containing file.
+func getModuleDir(file string) (string, error) {
+	dir := filepath.Dir(file)
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
 		}
 
-		found := false
-		// TODO: survive package aliasing
-		// TODO: detect Assert symbol usage which is not call, refuse it
-		// TODO: detect Fuse symbol usage which is not call/outside of TestMain, refuse it
-		for _, decl := range root.Decls {
-			// first, find Test<Name>(*testing.T) functions
-			fun, ok := decl.(*ast.FuncDecl)
-			if !ok || len(fun.Type.Params.List) != 1 {
-				continue
-			}
+		if dir == filepath.Dir(dir) {
+			return "", errors.New("module directory not found")
+		}
 
-			argTypeStr := sprintCode(fun.Type.Params.List[0].Type)
-			if argTypeStr == "*testing.M" {
-				// remove pa.Fuse() call
-				astutil.Apply(fun.Body, nil, func(c *astutil.Cursor) bool {
-					n := c.Node()
+		dir = filepath.Dir(dir)
+	}
+}
 
-					call, ok := n.(*ast.CallExpr)
-					if !ok {
-						return true
-					}
+// _rerunTestFlags are test flags forwarded to rerun, others, like
+// -test.testlogfile or -test.coverprofile, are specific to current run, and
+// -test.v is set by -json of rerun.
+var _rerunTestFlags = []string{
+	"bench", "benchmem", "benchtime", "count", "cpu", "failfast", "list",
+	"parallel", "run", "short", "shuffle", "skip", "timeout",
+}
 
-					selector, ok := call.Fun.(*ast.SelectorExpr)
-					if !ok || sprintCode(selector) != pkg.Name+".Fuse" {
-						return true
-					}
+// buildFlags returns build flags of current test binary taken from its build
+// info.
+func buildFlags() []string {
+	info, ok := rtdebug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
 
-					found = true
+	var flags []string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "-race":
+			if setting.Value == "true" {
+				flags = append(flags, "-race")
+			}
+		case "-tags", "-gcflags", "-ldflags", "-asmflags":
+			flags = append(flags, setting.Key+"="+setting.Value)
+		}
+	}
+	return flags
+}
 
-					c.Replace(ast.Expr(&ast.Ident{}))
+// rerunArgs returns go test arguments reproducing current test run of package
+// pkg: its build flags and set test flags. Output is in JSON, so that results
+// of tests are known.
+func rerunArgs(pkg string) []string {
+	args := append([]string{"test", "-json"}, buildFlags()...)
+	args = append(args, pkg)
+
+	flag.Visit(func(f *flag.Flag) {
+		name, ok := strings.CutPrefix(f.Name, "test.")
+		if ok && slices.Contains(_rerunTestFlags, name) {
+			args = append(args, "-test."+name+"="+f.Value.String())
+		}
+	})
+	return args
+}
 
-					return true
-				})
+// rewriteTests rewrites Assert and Require calls in test files of package
+// pkgRelDir of module copy in tmpDir. Package is loaded with types, so that
+// untyped constants are left as they are.
+func rewriteTests(tmpDir, moduleDir, pkgRelDir string) error {
+	cfg := &packages.Config{ //nolint:exhaustruct // defaults are fine
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedImports |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
+		Dir:        tmpDir,
+		Tests:      true,
+		BuildFlags: buildFlags(),
+	}
+	pkgs, err := packages.Load(cfg, "./"+filepath.ToSlash(pkgRelDir))
+	if err != nil {
+		return fmt.Errorf("load package: %w", err)
+	}
 
+	for _, pkg := range pkgs {
+		for _, root := range pkg.Syntax {
+			filename := pkg.Fset.File(root.Pos()).Name()
+			if !strings.HasSuffix(filename, "_test.go") {
 				continue
 			}
-			if argTypeStr != "*testing.T" {
-				continue
+
+			stat, err := os.Stat(filename)
+			if err != nil {
+				return fmt.Errorf("stat test file %s: %w", filename, err)
+			}
+			src, err := os.ReadFile(filename)
+			if err != nil {
+				return fmt.Errorf("read test file %s: %w", filename, err)
 			}
 
-			astutil.Apply(fun.Body, nil, func(c *astutil.Cursor) bool {
-				n := c.Node()
+			// positions in rewritten file refer to original one
+			original := filepath.Join(moduleDir, pkgRelDir, filepath.Base(filename))
+			rewritten, ok, err := powerassert.RewriteFile(original, pkg.Fset, root, src, pkg.TypesInfo)
+			if err != nil {
+				return fmt.Errorf("rewrite test file: %w", err)
+			}
+			if !ok {
+				continue
+			}
 
-				nes, ok := n.(*ast.ExprStmt)
-				if !ok {
-					return true
-				}
+			debugf("rewriting %s", filename)
+			if err := os.WriteFile(filename, rewritten, stat.Mode()); err != nil {
+				return fmt.Errorf("write rewritten file %s: %w", filename, err)
+			}
+		}
+	}
+	return nil
+}
 
-				// second, find Assert(t, <predicate>) calls
-				call, ok := nes.X.(*ast.CallExpr)
-				if !ok {
-					return true
-				}
+// testEvent is event of go test -json output, see cmd/test2json.
+type testEvent struct {
+	Action     string
+	Test       string
+	Output     string
+	OutputType string
+}
 
-				selector, ok := call.Fun.(*ast.SelectorExpr)
-				if !ok {
-					return true
-				}
-				var finalCall *ast.SelectorExpr
-				switch sprintCode(selector) {
-				case pkg.Name + ".Assert":
-					finalCall = &ast.SelectorExpr{
-						X:   pkgRoot,
-						Sel: ast.NewIdent("ZZZAssert"),
-					}
-				case pkg.Name + ".Require":
-					finalCall = &ast.SelectorExpr{
-						X:   pkgRoot,
-						Sel: ast.NewIdent("ZZZRequire"),
-					}
-				default:
-					return true
-				}
+// rerunResult is result of rerun of package tests.
+type rerunResult struct {
+	// events are output events of tests, in order
+	events []testEvent
+	// failed are names of failed tests
+	failed map[string]bool
+}
 
-				found = true
-
-				c.Replace(&ast.BlockStmt{
-					List: []ast.Stmt{
-						&ast.AssignStmt{ // zzz := assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZNew("2+2 == 5")
-							Tok: token.DEFINE,
-							Lhs: []ast.Expr{&ast.Ident{Name: "zzz"}},
-							Rhs: []ast.Expr{
-								&ast.CallExpr{
-									Fun: &ast.SelectorExpr{
-										X:   pkgRoot,
-										Sel: ast.NewIdent("ZZZNew"),
-									},
-									Args: []ast.Expr{
-										&ast.BasicLit{
-											Kind:  token.STRING,
-											Value: strconv.Quote(sprintCode(call.Args[1])),
-										},
-									},
-								},
-							},
-						},
-						&ast.ExprStmt{ // assert.ZZZAssert(t, zzz, <fused predicate expression>)
-							X: &ast.CallExpr{
-								Fun: finalCall,
-								Args: []ast.Expr{
-									ast.NewIdent("t"),
-									ast.NewIdent("zzz"),
-									rewriteExpr(call.Args[1], call.Args[1].Pos()),
-								},
-							},
-						},
-					},
-				})
-
-				return true
-			})
-		}
-		if !found {
+// output returns output of test name and its subtests in rerun, without
+// framing lines.
+func (r rerunResult) output(name string) string {
+	var sb strings.Builder
+	for _, e := range r.events {
+		if e.Test != name && !strings.HasPrefix(e.Test, name+"/") {
 			continue
 		}
 
-		debugf("rewriting %s", fileRelPath)
-		if err := os.WriteFile(fileRelPath, []byte(sprintCode(root)), stat.Mode()); err != nil {
-			return fmt.Errorf("write rewritten file %s: %w", fileRelPath, err)
+		if e.OutputType != "frame" {
+			sb.WriteString(e.Output)
 		}
 	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// run reruns tests of package containing file, from temporary copy of module
+// with Assert and Require calls in package test files rewritten, and returns
+// results of tests. Error is returned only if rerun failed not because of
+// failed tests.
+func run(file string) (rerunResult, error) {
+	res := rerunResult{events: nil, failed: map[string]bool{}}
+	moduleDir, err := getModuleDir(file)
+	if err != nil {
+		return res, err
+	}
+	debugf("module dir %s", moduleDir)
+
+	pkgRelDir, err := filepath.Rel(moduleDir, filepath.Dir(file))
+	if err != nil {
+		return res, fmt.Errorf("get package dir: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "assert.*")
+	if err != nil {
+		return res, fmt.Errorf("create temp dir: %w", err)
+	}
+	if !debug {
+		defer os.RemoveAll(tmpDir)
+	}
+	debugf("temp dir %s created", tmpDir)
+
+	// TODO: copy _test.go files, link everything besides
+	if err := os.CopyFS(tmpDir, os.DirFS(moduleDir)); err != nil {
+		return res, fmt.Errorf("copy project to temp dir: %w", err)
+	}
+
+	if err := rewriteTests(tmpDir, moduleDir, pkgRelDir); err != nil {
+		return res, err
+	}
 
-	// TODO: pass args
-	cmd := exec.Command("go", "test", "./...")
+	args := rerunArgs("./" + filepath.ToSlash(pkgRelDir))
+	debugf("running go %v", args)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = tmpDir
 	cmd.Env = append(os.Environ(), "ASSERT_MODULE_DIR="+moduleDir)
-	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("run tests: %w", err)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return res, fmt.Errorf("get rewritten tests output: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return res, fmt.Errorf("run rewritten tests: %w", err)
 	}
 
-	return nil
+	// output not related to tests, e.g. build errors
+	var pkgOutput strings.Builder
+	for dec := json.NewDecoder(stdout); ; {
+		var e testEvent
+		if err := dec.Decode(&e); err != nil {
+			if !errors.Is(err, io.EOF) {
+				_, _ = io.Copy(&pkgOutput, io.MultiReader(dec.Buffered(), stdout))
+			}
+			break
+		}
+
+		switch {
+		case e.Test == "":
+			pkgOutput.WriteString(e.Output)
+		case e.Action == "output":
+			res.events = append(res.events, e)
+		case e.Action == "fail":
+			res.failed[e.Test] = true
+		}
+	}
+	if err := cmd.Wait(); err != nil && len(res.failed) == 0 {
+		return res, fmt.Errorf("run rewritten tests: %w\n%s", err, pkgOutput.String())
+	}
+
+	return res, nil
 }
@@ -1,16 +1,25 @@
 package assert_test
 
 import (
+	"bytes"
 	"cmp"
+	"fmt"
+	"maps"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/rprtr258/assert"
+	pa "github.com/rprtr258/assert" //nolint:stylecheck // calls through aliased import are rewritten too
 	"github.com/rprtr258/assert/internal/ass"
+	"github.com/rprtr258/assert/internal/scuf"
 
 	"golang.org/x/tools/txtar"
 )
@@ -59,6 +68,7 @@ func TestExample(t *testing.T) {
 	assert.Assert(t, *new(int) == 1)
 	assert.Assert(t, len(map[int]int{1: two}) == 0)
 	assert.Assert(t, &s == &s)
+	assert.Assert(t, len("日本語") == two)
 
 	t.Run("require", func(t *testing.T) {
 		assert.Require(t, two != 1+1)
@@ -92,3 +102,849 @@ func TestExample(t *testing.T) {
 		ass.Equal(t, golden, diagram)
 	}
 }
+
+type assertSuite struct {
+	tb testing.TB
+}
+
+func (s assertSuite) check(x int) {
+	assert.Assert(s.tb, x == 1)
+}
+
+func assertPositive(tb testing.TB, x int) {
+	tb.Helper()
+	assert.Assert(tb, x > 0)
+}
+
+// TestCallSites checks that Assert and Require are rewritten wherever they
+// are called, with any testing.TB expression.
+func TestCallSites(t *testing.T) {
+	assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZSnapshot = true
+	assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZCapturedSnapshots = nil
+
+	t.Run("closure", func(tt *testing.T) {
+		assert.Require(tt, 1 == 2)
+	})
+	assertPositive(t, -1)
+	assertSuite{t}.check(2)
+	suites := []assertSuite{{t}}
+	assert.Assert(suites[0].tb, len(suites) == 0)
+
+	got := assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__.ZZZCapturedSnapshots
+	ass.Equal(t, 4, len(got))
+	for i, prefix := range []string{
+		"require failed:\n1 == 2",
+		"assert failed:\nx > 0",
+		"assert failed:\nx == 1",
+		"assert failed:\nlen(suites) == 0",
+	} {
+		ass.True(t, strings.HasPrefix(got[i], prefix))
+	}
+}
+
+// TestAliasedImport checks that calls through aliased import are rewritten.
+func TestAliasedImport(t *testing.T) {
+	got := captureDiagrams(t, func() {
+		x := 1
+		pa.Assert(t, x == 1)
+		pa.Assert(pa.Wrap(t), x == 2)
+	})
+	ass.Equal(t, []string{`assert failed:
+x == 2
+^ ^
+| false
+1`}, got)
+}
+
+// recordT records reported errors instead of failing the test.
+type recordT struct {
+	testing.TB
+	errs []string
+}
+
+func (t *recordT) Fail()             {}
+func (t *recordT) Error(args ...any) { t.errs = append(t.errs, fmt.Sprint(args...)) }
+
+// TestFailureLine checks that failures point to lines of original Assert
+// calls, both with -toolexec and in rerun from temporary copy of module.
+func TestFailureLine(t *testing.T) {
+	internals := &assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__
+	snapshot := internals.ZZZSnapshot
+	internals.ZZZSnapshot = false
+	defer func() {
+		internals.ZZZSnapshot = snapshot
+	}()
+
+	rec := &recordT{TB: t, errs: nil}
+	x := 1
+	_, file, line, _ := runtime.Caller(0)
+	assert.Assert(assert.Wrap(rec).With("x", x), x == 2, "x is %d", x)
+
+	ass.Equal(t, 1, len(rec.errs))
+	got := scuf.Strip(rec.errs[0])
+	ass.SContains(t, file+":"+strconv.Itoa(line+1), got)
+	ass.SContains(t, "Message:\n    x is 1\nx:\n    1\nassert failed:\n    x == 2", got)
+}
+
+func BenchmarkAssert(b *testing.B) {
+	for b.Loop() {
+		assert.Assert(b, b.N >= 0)
+	}
+}
+
+func FuzzAssert(f *testing.F) {
+	f.Add(1)
+	f.Fuzz(func(t *testing.T, x int) {
+		assert.Assert(t, x == x)
+	})
+}
+
+// captureDiagrams returns uncoloured diagrams of failed assertions made in f.
+func captureDiagrams(t *testing.T, f func()) []string {
+	t.Helper()
+	internals := &assert.SECRET_INTERNALS_DO_NOT_USE_OR_YOU_WILL_BE_FIRED__
+	snapshot := internals.ZZZSnapshot
+	internals.ZZZSnapshot = true
+	internals.ZZZCapturedSnapshots = nil
+	defer func() {
+		internals.ZZZSnapshot = snapshot
+	}()
+
+	f()
+
+	// addresses differ between runs
+	addr := regexp.MustCompile(`0x[0-9a-f]+`)
+	res := make([]string, len(internals.ZZZCapturedSnapshots))
+	for i, diagram := range internals.ZZZCapturedSnapshots {
+		res[i] = addr.ReplaceAllString(scuf.Strip(diagram), "0x...")
+	}
+	return res
+}
+
+type point struct{ x, y int }
+
+type counter struct{ n int }
+
+func (c *counter) Inc() int {
+	c.n++
+	return c.n
+}
+
+func pair[A, B any](a A, _ B) A {
+	return a
+}
+
+func apply(f func(int, string) int) int {
+	return f(1, "a")
+}
+
+func call(f func() int) int {
+	return f()
+}
+
+// TestExprKinds checks diagrams and semantics of rewritten expressions of
+// every kind.
+func TestExprKinds(t *testing.T) {
+	two := 2
+	for name, test := range map[string]struct {
+		f    func(t *testing.T)
+		want []string
+	}{
+		"type assertion": {
+			f: func(t *testing.T) {
+				var v any = 1
+				assert.Assert(t, v.(int) == two)
+			},
+			want: []string{
+				`assert failed:
+v.(int) == two
+^ ^     ^  ^
+| |     |  2
+| |     false
+| 1
+1`,
+			},
+		},
+		"generic instantiation": {
+			f: func(t *testing.T) {
+				assert.Assert(t, pair[int, string](1, "a") == two)
+				assert.Assert(t, apply(pair[int, string]) == two)
+			},
+			want: []string{
+				`assert failed:
+pair[int, string](1, "a") == two
+^                         ^  ^
+|                         |  2
+|                         false
+1`,
+				`assert failed:
+apply(pair[int, string]) == two
+^                        ^  ^
+|                        |  2
+|                        false
+1`,
+			},
+		},
+		"address of": {
+			f: func(t *testing.T) {
+				s := point{1, 2}
+				p := &s
+				assert.Assert(t, p == &s) // not a copy of s
+				assert.Assert(t, &s.x != &p.x)
+				assert.Assert(t, (&point{x: two}).x == 0)
+			},
+			want: []string{
+				`assert failed:
+&s.x != &p.x
+^^ ^ ^  ^^ ^
+|| | |  || 1
+|| | |  |&assert_test.point{x: 1, y: 2}
+|| | |  &1
+|| | false
+|| 1
+|assert_test.point{x: 1, y: 2}
+&1`,
+				`assert failed:
+(&point{x: two}).x == 0
+ ^         ^     ^ ^
+ |         |     | false
+ |         |     2
+ |         2
+ &assert_test.point{x: 2, y: 0}`,
+			},
+		},
+		"slice of array": {
+			f: func(t *testing.T) {
+				arr := [3]int{1, 2, 3}
+				assert.Assert(t, len(arr[1:]) == 0)
+			},
+			want: []string{
+				`assert failed:
+len(arr[1:]) == 0
+^   ^  ^     ^
+|   |  |     false
+|   |  []int{2, 3}
+|   [3]int{1, 2, 3}
+2`,
+			},
+		},
+		"pointer method": {
+			f: func(t *testing.T) {
+				var c counter
+				assert.Assert(t, c.Inc() == 2)
+				assert.Assert(t, c.n == 1) // called on c itself
+			},
+			want: []string{
+				`assert failed:
+c.Inc() == 2
+^ ^     ^
+| |     false
+| 1
+assert_test.counter{n: 0}`,
+			},
+		},
+		"method value": {
+			f: func(t *testing.T) {
+				var c counter
+				assert.Assert(t, call(c.Inc) == 2)
+				assert.Assert(t, c.n == 1) // bound to c itself
+			},
+			want: []string{
+				`assert failed:
+call(c.Inc) == 2
+^    ^ ^    ^
+|    | |    false
+|    | func() int {github.com/rprtr258/assert_test.(*counter).Inc-fm at <autogenerated>:1}
+|    assert_test.counter{n: 0}
+1`,
+			},
+		},
+		"short-circuit": {
+			f: func(t *testing.T) {
+				calls := 0
+				f := func() bool {
+					calls++
+					return true
+				}
+				assert.Assert(t, two == 1 && f())
+				assert.Assert(t, two == 2 || f())
+				assert.Assert(t, calls == 0)
+			},
+			want: []string{
+				`assert failed:
+two == 1 && f()
+^   ^    ^  ^
+|   |    |  <not evaluated>
+|   |    false
+|   false
+2`,
+			},
+		},
+		"composite literals": {
+			f: func(t *testing.T) {
+				assert.Assert(t, len([]point{{x: 1}, {y: two}}) == 0)
+				assert.Assert(t, len(map[point]int{{two, 1}: two}) == 0)
+				assert.Assert(t, len([]*point{{x: two}}) == 0)
+			},
+			want: []string{
+				`assert failed:
+len([]point{{x: 1}, {y: two}}) == 0
+^   ^                   ^      ^
+|   |                   |      false
+|   |                   2
+|   []assert_test.point{assert_test.point{...}, assert_test.point{...}}
+2`,
+				`assert failed:
+len(map[point]int{{two, 1}: two}) == 0
+^   ^              ^        ^     ^
+|   |              |        |     false
+|   |              |        2
+|   |              2
+|   map[assert_test.point]int{assert_test.point{x: 2, y: 1}: 2}
+1`,
+				`assert failed:
+len([]*point{{x: two}}) == 0
+^   ^            ^      ^
+|   |            |      false
+|   |            2
+|   []*assert_test.point{&assert_test.point{x: 2, y: 0}}
+1`,
+			},
+		},
+		"map keys": {
+			f: func(t *testing.T) {
+				key := "k"
+				assert.Assert(t, len(map[string]int{key: two}) == 0)
+			},
+			want: []string{
+				`assert failed:
+len(map[string]int{key: two}) == 0
+^   ^              ^    ^     ^
+|   |              |    |     false
+|   |              |    2
+|   |              "k"
+|   map[string]int{"k": 2}
+1`,
+			},
+		},
+		"receive": {
+			f: func(t *testing.T) {
+				ch := make(chan int, 1)
+				ch <- 1
+				assert.Assert(t, <-ch == two)
+			},
+			want: []string{
+				`assert failed:
+<-ch == two
+^ ^  ^  ^
+| |  |  2
+| |  false
+| (chan int)(0x..., len: 1, cap: 1, open)
+1`,
+			},
+		},
+		"types as arguments": {
+			f: func(t *testing.T) {
+				assert.Assert(t, len(make([]point, two)) == 0)
+				assert.Assert(t, new(point) == nil)
+			},
+			want: []string{
+				`assert failed:
+len(make([]point, two)) == 0
+^   ^             ^     ^
+|   |             |     false
+|   |             2
+|   []assert_test.point{assert_test.point{...}, assert_test.point{...}}
+2`,
+				`assert failed:
+new(point) == nil
+^          ^
+|          false
+&assert_test.point{x: 0, y: 0}`,
+			},
+		},
+		"package members": {
+			f: func(t *testing.T) {
+				assert.Assert(t, math.Abs(-math.Pi) == 3)
+			},
+			want: []string{
+				`assert failed:
+math.Abs(-math.Pi) == 3
+^        ^     ^   ^
+|        |     |   false
+|        |     3.141593
+|        -3.141593
+3.141593`,
+			},
+		},
+		"untyped constants": {
+			f: func(t *testing.T) {
+				const three = 3
+				f, u := 1.5, uint64(1)
+				assert.Assert(t, f == -1)
+				assert.Assert(t, f == three)
+				assert.Assert(t, u == math.MaxUint64)
+				assert.Assert(t, math.Abs(-1) == f)
+				assert.Assert(t, two == three) // typed as default, so captured
+			},
+			want: []string{
+				`assert failed:
+f == -1
+^ ^
+| false
+1.500000`,
+				`assert failed:
+f == three
+^ ^
+| false
+1.500000`,
+				`assert failed:
+u == math.MaxUint64
+^ ^
+| false
+1`,
+				`assert failed:
+math.Abs(-1) == f
+^            ^  ^
+|            |  1.500000
+|            false
+1.000000`,
+				`assert failed:
+two == three
+^   ^  ^
+|   |  3
+|   false
+2`,
+			},
+		},
+		"function literal": {
+			f: func(t *testing.T) {
+				assert.Assert(t, func() int { return two }() == 1)
+			},
+			want: []string{
+				`assert failed:
+func() int { return two }() == 1
+^                           ^
+|                           false
+2`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := captureDiagrams(t, func() { test.f(t) })
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
+
+// TestDiagramLayout checks diagrams of predicates spanning several lines,
+// of values wider than diagram and diffs of compared values.
+func TestDiagramLayout(t *testing.T) {
+	for name, test := range map[string]struct {
+		f    func(t *testing.T)
+		want []string
+	}{
+		"multi-line": {
+			f: func(t *testing.T) {
+				x, y := 1, 2
+				assert.Assert(t, x == 2 &&
+					y == 1)
+				assert.Assert(t,
+					x+
+						y == 4)
+			},
+			want: []string{
+				`assert failed:
+x == 2 &&
+^ ^    ^
+| |    false
+| false
+1
+y == 1
+  ^
+  <not evaluated>`,
+				`assert failed:
+x+
+^^
+|3
+1
+y == 4
+^ ^
+| false
+2`,
+			},
+		},
+		"legend": {
+			f: func(t *testing.T) {
+				cfg := assert.DefaultConfig()
+				cfg.DiagramWidth = 30
+				assert.SetConfig(t, cfg)
+
+				s := strings.Repeat("ab", 10)
+				assert.Assert(t, len(s) == 0 || strings.HasPrefix(s, "b"))
+			},
+			want: []string{
+				`assert failed:
+len(s) == 0 || strings.HasPrefix(s, "b")
+^   ^  ^    ^  ^                 ^
+|   |  |    |  |                 [1]
+|   |  |    |  false
+|   |  |    false
+|   |  false
+|   "abababababababababab"
+20
+
+[1] "abababababababababab"`,
+			},
+		},
+		"struct diff": {
+			f: func(t *testing.T) {
+				got := point{1, 2}
+				assert.Assert(t, got == point{1, 3})
+			},
+			want: []string{
+				`assert failed:
+got == point{1, 3}
+^   ^  ^
+|   |  assert_test.point{x: 1, y: 3}
+|   false
+assert_test.point{x: 1, y: 2}
+Not equal:
+point{1, 3}.y != got.y:
+	3 !=
+	2`,
+			},
+		},
+		"array diff": {
+			f: func(t *testing.T) {
+				got := [3]int{1, 2, 3}
+				want := [3]int{1, 2, 4}
+				assert.Assert(t, got == want && len(got) == 3)
+			},
+			want: []string{
+				`assert failed:
+got == want && len(got) == 3
+^   ^  ^    ^           ^
+|   |  |    |           <not evaluated>
+|   |  |    false
+|   |  [3]int{1, 2, 4}
+|   false
+[3]int{1, 2, 3}
+Not equal:
+want[2] != got[2]:
+	4 !=
+	3`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := captureDiagrams(t, func() { test.f(t) })
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
+
+// TestTopComparison checks diffs of operands of comparison deciding
+// predicate.
+func TestTopComparison(t *testing.T) {
+	for name, test := range map[string]struct {
+		f    func(t *testing.T)
+		want []string
+	}{
+		"strings": {
+			f: func(t *testing.T) {
+				got := "hello world"
+				assert.Assert(t, got == "hello, world")
+				assert.Assert(t, len(got) == 0)
+			},
+			want: []string{
+				`assert failed:
+got == "hello, world"
+^   ^  ^
+|   |  "hello, world"
+|   false
+"hello world"
+Not equal:
+"hello, world" != got:
+	"hello, world" !=
+	"hello world"`,
+				`assert failed:
+len(got) == 0
+^   ^    ^
+|   |    false
+|   "hello world"
+11`,
+			},
+		},
+		"not equal": {
+			f: func(t *testing.T) {
+				got := []string{"a"}
+				assert.Assert(t, !reflect.DeepEqual(got, []string{"a"}))
+				assert.Assert(t, point{1, 2} != point{1, 2})
+			},
+			want: []string{
+				`assert failed:
+!reflect.DeepEqual(got, []string{"a"})
+^^                 ^    ^
+||                 |    []string{"a"}
+||                 []string{"a"}
+|true
+false
+Equal:
+[]string{"a"} and got are equal, asserted not to, value is:
+	[]string{
+	    "a",
+	}`,
+				`assert failed:
+point{1, 2} != point{1, 2}
+^           ^  ^
+|           |  assert_test.point{x: 1, y: 2}
+|           false
+assert_test.point{x: 1, y: 2}
+Equal:
+point{1, 2} and point{1, 2} are equal, asserted not to, value is:
+	assert_test.point{
+	    x: 1,
+	    y: 2,
+	}`,
+			},
+		},
+		"equality functions": {
+			f: func(t *testing.T) {
+				assert.Assert(t, slices.Equal([]int{1, 2}, []int{1, 3}))
+				assert.Assert(t, maps.Equal(map[string]int{"a": 1}, map[string]int{"a": 2}))
+				assert.Assert(t, bytes.Equal([]byte("ab"), []byte("ac")))
+			},
+			want: []string{
+				`assert failed:
+slices.Equal([]int{1, 2}, []int{1, 3})
+^            ^            ^
+|            |            []int{1, 3}
+|            []int{1, 2}
+false
+Not equal:
+[]int{1, 3}[1] != []int{1, 2}[1]:
+	3 !=
+	2`,
+				`assert failed:
+maps.Equal(map[string]int{"a": 1}, map[string]int{"a": 2})
+^          ^                       ^
+|          |                       map[string]int{"a": 2}
+|          map[string]int{"a": 1}
+false
+Not equal:
+map[string]int{"a": 2}[a] != map[string]int{"a": 1}[a]:
+	2 !=
+	1`,
+				`assert failed:
+bytes.Equal([]byte("ab"), []byte("ac"))
+^           ^             ^
+|           |             []uint8{97, 99}
+|           []uint8{97, 98}
+false
+Not equal:
+[]byte("ac")[1] != []byte("ab")[1]:
+	99 !=
+	98`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := captureDiagrams(t, func() { test.f(t) })
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
+
+// TestMessage checks that messages and context of wrapped tests are shown
+// above diagrams.
+func TestMessage(t *testing.T) {
+	for name, test := range map[string]struct {
+		f    func(t *testing.T)
+		want []string
+	}{
+		"format": {
+			f: func(t *testing.T) {
+				x := 1
+				assert.Assert(t, x == 2, "x is %d", x)
+				assert.Require(t, x == 3, "plain %d")
+				args := []any{"x is still %d", x}
+				assert.Assert(t, x == 4, args...)
+			},
+			want: []string{
+				`Message:
+x is 1
+assert failed:
+x == 2
+^ ^
+| false
+1`,
+				`Message:
+plain %d
+require failed:
+x == 3
+^ ^
+| false
+1`,
+				`Message:
+x is still 1
+assert failed:
+x == 4
+^ ^
+| false
+1`,
+			},
+		},
+		"wrapped": {
+			f: func(t *testing.T) {
+				x := 1
+				assert.Assert(assert.Wrap(t).Msg("checking x").With("x", x), x == 2)
+				assert.Assert(assert.Wrap(assert.Wrap(t).Msg("outer")).With("x", x).Msg("inner"), x == 4, "call")
+				assert.Require(assert.Must(t).Msgf("try %d", 2), x == 3, "message")
+			},
+			want: []string{
+				`Message:
+checking x
+x:
+1
+assert failed:
+x == 2
+^ ^
+| false
+1`,
+				`Message:
+outer
+inner
+call
+x:
+1
+assert failed:
+x == 4
+^ ^
+| false
+1`,
+				`Message:
+try 2
+message
+require failed:
+x == 3
+^ ^
+| false
+1`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := captureDiagrams(t, func() { test.f(t) })
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
+
+// TestSideEffects checks that sub-expressions are evaluated once, in source
+// order, and their values are captured right after evaluation.
+func TestSideEffects(t *testing.T) {
+	for name, test := range map[string]struct {
+		f    func(t *testing.T)
+		want []string
+	}{
+		"calls": {
+			f: func(t *testing.T) {
+				n := 0
+				next := func() int {
+					n++
+					return n
+				}
+				assert.Assert(t, next() == next())
+				ass.Equal(t, 2, n)
+			},
+			want: []string{
+				`assert failed:
+next() == next()
+^      ^  ^
+|      |  2
+|      false
+1`,
+			},
+		},
+		"receives": {
+			f: func(t *testing.T) {
+				ch := make(chan int, 2)
+				ch <- 1
+				ch <- 2
+				assert.Assert(t, <-ch == <-ch)
+				ass.Equal(t, 0, len(ch))
+			},
+			want: []string{
+				`assert failed:
+<-ch == <-ch
+^ ^  ^  ^ ^
+| |  |  | (chan int)(0x..., len: 1, cap: 2, open)
+| |  |  2
+| |  false
+| (chan int)(0x..., len: 2, cap: 2, open)
+1`,
+			},
+		},
+		"map writes": {
+			f: func(t *testing.T) {
+				m := map[string]int{}
+				set := func(m map[string]int, k string) int {
+					m[k] = len(m)
+					return len(m)
+				}
+				assert.Assert(t, set(m, "a") == set(m, "b"))
+				ass.Equal(t, map[string]int{"a": 0, "b": 1}, m)
+			},
+			want: []string{
+				`assert failed:
+set(m, "a") == set(m, "b")
+^   ^       ^  ^   ^
+|   |       |  |   map[string]int{"a": 0}
+|   |       |  2
+|   |       false
+|   map[string]int{}
+1`,
+			},
+		},
+		"short-circuit": {
+			f: func(t *testing.T) {
+				n := 0
+				next := func() int {
+					n++
+					return n
+				}
+				assert.Assert(t, next() == 2 && (next() == 0 || next() == 1))
+				assert.Assert(t, next() == 2 && (next() == 3 || next() == 0) && next() == 0)
+				ass.Equal(t, 4, n)
+			},
+			want: []string{
+				`assert failed:
+next() == 2 && (next() == 0 || next() == 1)
+^      ^    ^               ^
+|      |    |               <not evaluated>
+|      |    false
+|      false
+1`,
+				`assert failed:
+next() == 2 && (next() == 3 || next() == 0) && next() == 0
+^      ^    ^   ^      ^    ^         ^     ^  ^      ^
+|      |    |   |      |    |         |     |  |      false
+|      |    |   |      |    |         |     |  4
+|      |    |   |      |    |         |     false
+|      |    |   |      |    |         <not evaluated>
+|      |    |   |      |    true
+|      |    |   |      true
+|      |    |   3
+|      |    true
+|      true
+2`,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got := captureDiagrams(t, func() { test.f(t) })
+			ass.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package assert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rprtr258/assert/internal/scuf"
+)
+
+// _htmlReportCounter makes report filenames unique within process.
+var _htmlReportCounter atomic.Int64
+
+var _reUnsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// testName returns name of the test if t provides it.
+func testName(t T) string {
+	switch tt := t.(type) {
+	case *tT:
+		return testName(tt.T)
+	case tT:
+		return testName(tt.T)
+	case interface{ Name() string }:
+		return tt.Name()
+	default:
+		return ""
+	}
+}
+
+func renderHTMLReport(name string, lines []labeledContent) string {
+	var sb strings.Builder
+	sb.WriteString(`<section class="assert-failure">` + "\n")
+	sb.WriteString("<style>\n" + scuf.HTMLStyle + "</style>\n")
+	if name != "" {
+		sb.WriteString("<h3>" + scuf.HTML(name) + "</h3>\n")
+	}
+	sb.WriteString("<dl>\n")
+	for _, line := range lines {
+		sb.WriteString("<dt>" + scuf.HTML(line.label) + "</dt>\n")
+		sb.WriteString("<dd><pre>" + scuf.HTML(line.content) + "</pre></dd>\n")
+	}
+	sb.WriteString("</dl>\n</section>\n")
+	return sb.String()
+}
+
+// writeHTMLReport writes failure report lines to report directory if one is
+// configured for the test.
+func writeHTMLReport(t T, lines []labeledContent) {
+	t.Helper()
+	dir := GetConfig(t).HTMLReportDir
+	if dir == "" {
+		return
+	}
+
+	name := testName(t)
+	filename := fmt.Sprintf(
+		"%s-%d.html",
+		strings.Trim(_reUnsafeFilenameChars.ReplaceAllString(name, "_"), "_"),
+		_htmlReportCounter.Add(1),
+	)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Errorf("create html report dir: %s", err.Error())
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, filename), []byte(renderHTMLReport(name, lines)), 0o644); err != nil {
+		t.Errorf("write html report: %s", err.Error())
+	}
+}
@@ -2,8 +2,7 @@ package assert
 
 import (
 	"fmt"
-
-	"github.com/rprtr258/assert/internal/pp"
+	"slices"
 )
 
 // T is the interface common to T, B, and F.
@@ -87,13 +86,23 @@ func Wrap(t T) *tT {
 }
 
 func (t *tT) Msg(msg string) *tT {
-	t.kvs = append(t.kvs, labeledContent{
-		label:   "Message",
-		content: msg,
-	})
+	t.kvs = withMessage(t.kvs, msg)
 	return t
 }
 
+// withMessage adds msg to context kvs, it is shown on new line of the first
+// message or above other context if there are no messages.
+func withMessage(kvs []labeledContent, msg string) []labeledContent {
+	for i, kv := range kvs {
+		if kv.label == "Message" {
+			kvs = slices.Clone(kvs)
+			kvs[i].content += "\n" + msg
+			return kvs
+		}
+	}
+	return append([]labeledContent{{"Message", msg}}, kvs...)
+}
+
 func (t *tT) Msgf(format string, args ...any) *tT {
 	return t.Msg(fmt.Sprintf(format, args...))
 }
@@ -101,7 +110,36 @@ func (t *tT) Msgf(format string, args ...any) *tT {
 func (t *tT) With(key string, value any) *tT {
 	t.kvs = append(t.kvs, labeledContent{
 		label:   key,
-		content: pp.Sprint(value),
+		content: GetConfig(t.T).printer().Sprint(value),
 	})
 	return t
 }
+
+// unwrap returns test wrapped by Wrap or Must with context added to it, or t
+// itself if it is not wrapped.
+func unwrap(t T) (inner T, must bool, kvs []labeledContent) {
+	switch tt := t.(type) {
+	case *tT:
+		inner, must, kvs = unwrap(tt.T)
+		return inner, must || tt.must, mergeContext(kvs, tt.kvs)
+	case tT:
+		inner, must, kvs = unwrap(tt.T)
+		return inner, must || tt.must, mergeContext(kvs, tt.kvs)
+	default:
+		return t, false, nil
+	}
+}
+
+// mergeContext returns context of wrapped test followed by context kvs of
+// wrapper, with messages of both under single header.
+func mergeContext(inner, kvs []labeledContent) []labeledContent {
+	res := slices.Clone(inner)
+	for _, kv := range kvs {
+		if kv.label == "Message" {
+			res = withMessage(res, kv.content)
+		} else {
+			res = append(res, kv)
+		}
+	}
+	return res
+}